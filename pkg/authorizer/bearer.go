@@ -0,0 +1,50 @@
+package authorizer
+
+import (
+	"strings"
+)
+
+const (
+	// MaxTokenSize is the maximal accepted length of authorization header in bytes.
+	// API Gateway limits a header value to 10 KB.
+	MaxTokenSize = 10 * 1024
+
+	bearerScheme = "bearer"
+)
+
+// ExtractToken returns the token from authorization header value.
+// Both `Bearer <token>` (scheme is case-insensitive) and a raw token are accepted,
+// other schemes fail with ErrUnsupportedScheme.
+func ExtractToken(header string) (string, error) {
+	if len(header) > MaxTokenSize {
+		return "", ErrTokenTooLarge
+	}
+
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return "", ErrTokenMissing
+	}
+
+	separator := strings.IndexAny(header, " \t")
+	if separator < 0 {
+		if strings.EqualFold(header, bearerScheme) {
+			return "", ErrTokenMissing
+		}
+		return header, nil
+	}
+
+	if !strings.EqualFold(header[:separator], bearerScheme) {
+		return "", ErrUnsupportedScheme
+	}
+
+	token := strings.TrimSpace(header[separator+1:])
+	if token == "" {
+		return "", ErrTokenMissing
+	}
+
+	if strings.ContainsAny(token, " \t") {
+		return "", ErrTokenMalformed
+	}
+
+	return token, nil
+}
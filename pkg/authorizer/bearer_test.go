@@ -0,0 +1,36 @@
+package authorizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    string
+		wantErr error
+	}{
+		{name: "raw", header: "header.payload.signature", want: "header.payload.signature"},
+		{name: "bearer", header: "Bearer header.payload.signature", want: "header.payload.signature"},
+		{name: "bearerLowercase", header: "bearer header.payload.signature", want: "header.payload.signature"},
+		{name: "bearerSpaces", header: "  Bearer   header.payload.signature ", want: "header.payload.signature"},
+		{name: "empty", header: "", wantErr: ErrTokenMissing},
+		{name: "bearerWithoutToken", header: "Bearer ", wantErr: ErrTokenMissing},
+		{name: "basic", header: "Basic dXNlcjpwYXNzd29yZA==", wantErr: ErrUnsupportedScheme},
+		{name: "extraParts", header: "Bearer header.payload.signature extra", wantErr: ErrTokenMalformed},
+		{name: "tooLarge", header: "Bearer " + strings.Repeat("a", MaxTokenSize), wantErr: ErrTokenTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := ExtractToken(tt.header)
+
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, token)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package builder
+
+import (
+	"fmt"
+)
+
+// CacheSafetyWarnings lists reasons why policies built from the document are not correct when API Gateway
+// caches authorizer results. A cached policy is reused for every method and path the caller invokes,
+// so rules must not depend on the called method or path. Empty result means the document is cache-safe.
+func CacheSafetyWarnings(document *PolicyDocument) []string {
+	var warnings []string
+
+	for i, rule := range document.Rules {
+		if rule.Condition == "" {
+			continue
+		}
+
+		condition, err := ruleCondition(rule)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("rule %d: %s", i, err))
+			continue
+		}
+
+		if condition.UsesRequest() && isDenyRule(rule) {
+			warnings = append(warnings, fmt.Sprintf(
+				"rule %d: deny condition %q depends on the called method or path, in cache-safe mode its routes are denied for all requests",
+				i, condition.String(),
+			))
+		} else if condition.UsesRequest() {
+			warnings = append(warnings, fmt.Sprintf(
+				"rule %d: condition %q depends on the called method or path, its result is cached for other requests",
+				i, condition.String(),
+			))
+		}
+	}
+
+	return warnings
+}
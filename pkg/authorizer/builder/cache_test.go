@@ -0,0 +1,149 @@
+package builder
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nordcloud/cognito-authorizer/pkg/authorizer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditionUsesRequest(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{expression: `claims.email_verified == true`, want: false},
+		{expression: `request.stage == "prod" && request.account == "123456789012"`, want: false},
+		{expression: `claims["custom:tenant"] == path.tenantId`, want: true},
+		{expression: `request.method == "GET"`, want: true},
+		{expression: `claims.admin == true || segment(request.resource, 1) == claims.sub`, want: true},
+	}
+
+	for _, tt := range tests {
+		condition, err := ParseCondition(tt.expression)
+
+		assert.Nil(t, err)
+		assert.Equal(t, tt.want, condition.UsesRequest(), tt.expression)
+	}
+}
+
+func TestCacheSafetyWarnings(t *testing.T) {
+	document, err := ParsePolicyDocument([]byte(testConditionPolicyDocument))
+	assert.Nil(t, err)
+
+	warnings := CacheSafetyWarnings(document)
+
+	assert.Equal(t, []string{
+		`rule 0: condition "claims[\"custom:tenant\"] == path.tenantId" depends on the called method or path, its result is cached for other requests`,
+	}, warnings)
+	assert.Empty(t, CacheSafetyWarnings(createTestPolicyDocument()))
+}
+
+func TestCacheSafetyWarningsInvalidCondition(t *testing.T) {
+	document := &PolicyDocument{Rules: []PolicyRule{{Condition: "claims.a =="}}}
+
+	warnings := CacheSafetyWarnings(document)
+
+	assert.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "rule 0: condition is invalid")
+}
+
+func TestDocumentPolicyBuilderCacheSafe(t *testing.T) {
+	builder := createTestConditionPolicyBuilder(t)
+	builder.CacheSafe = true
+	request := &authorizer.AuthorizerRequest{
+		Token: &authorizer.VerifiedToken{
+			ID:     &authorizer.IDTokenClaims{Groups: []string{"admins"}},
+			Claims: map[string]interface{}{"custom:tenant": "acme"},
+		},
+		MethodArn:      "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/tenants/acme",
+		PathParameters: map[string]string{"tenantId": "acme"},
+	}
+
+	policy, err := builder.BuildPolicyForRequest(request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []events.IAMPolicyStatement{
+		allowStatement("arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/*/tenants/*"),
+		allowStatement("arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/*/admin/*"),
+		{
+			Action:   []string{"execute-api:Invoke"},
+			Effect:   "Deny",
+			Resource: []string{"arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/*/admin/*"},
+		},
+	}, policy.Statement)
+}
+
+func TestDocumentPolicyBuilderCacheSafeDenied(t *testing.T) {
+	builder := createTestConditionPolicyBuilder(t)
+	builder.CacheSafe = true
+	request := &authorizer.AuthorizerRequest{
+		Token: &authorizer.VerifiedToken{
+			ID:     &authorizer.IDTokenClaims{},
+			Claims: map[string]interface{}{"custom:tenant": "acme"},
+		},
+		MethodArn:      "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/tenants/acme",
+		PathParameters: map[string]string{"tenantId": "acme"},
+	}
+
+	_, err := builder.BuildPolicyForRequest(request)
+
+	var denied *authorizer.AccessDeniedError
+	assert.True(t, errors.As(err, &denied))
+	assert.Equal(t, []string{"arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/*/*"}, denied.Resource)
+}
+
+const testTenantDenyPolicyDocument = `
+version: 1
+rules:
+  - groups: [users]
+    routes:
+      - {method: "*", resource: /tenants/*}
+      - {method: GET, resource: /items}
+  - condition: 'claims["custom:tenant"] != path.tenantId'
+    effect: deny
+    routes:
+      - {method: "*", resource: /tenants/*}
+`
+
+func TestDocumentPolicyBuilderCacheSafeDenyRuleUsingRequest(t *testing.T) {
+	document, err := ParsePolicyDocument([]byte(testTenantDenyPolicyDocument))
+	assert.Nil(t, err)
+	builder := &DocumentPolicyBuilder{
+		Context:   &authorizer.Context{Region: "eu-west-1", ApplicationID: "api-id", Stage: "prod"},
+		Document:  document,
+		CacheSafe: true,
+	}
+	request := &authorizer.AuthorizerRequest{
+		Token: &authorizer.VerifiedToken{
+			ID:     &authorizer.IDTokenClaims{Groups: []string{"users"}},
+			Claims: map[string]interface{}{"custom:tenant": "a"},
+		},
+		MethodArn:      "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/tenants/b",
+		PathParameters: map[string]string{"tenantId": "b"},
+	}
+
+	policy, err := builder.BuildPolicyForRequest(request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []events.IAMPolicyStatement{
+		allowStatement("arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/*/tenants/*"),
+		allowStatement("arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/items"),
+		{
+			Action:   []string{"execute-api:Invoke"},
+			Effect:   "Deny",
+			Resource: []string{"arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/*/tenants/*"},
+		},
+	}, policy.Statement)
+}
+
+func TestCacheSafetyWarningsDenyRule(t *testing.T) {
+	document, err := ParsePolicyDocument([]byte(testTenantDenyPolicyDocument))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{
+		`rule 1: deny condition "claims[\"custom:tenant\"] != path.tenantId" depends on the called method or path, in cache-safe mode its routes are denied for all requests`,
+	}, CacheSafetyWarnings(document))
+}
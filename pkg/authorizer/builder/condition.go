@@ -0,0 +1,208 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/nordcloud/cognito-authorizer/pkg/authorizer"
+)
+
+const (
+	// MaxConditionLength is the maximal length of condition expression.
+	MaxConditionLength = 4096
+	// MaxConditionDepth is the maximal nesting of condition expression.
+	MaxConditionDepth = 32
+)
+
+// ErrInvalidCondition is matched by errors returned when condition expression can not be parsed.
+var ErrInvalidCondition = errors.New("condition is invalid")
+
+// Condition is a boolean expression over token claims and the authorized request, e.g.
+//
+//	claims["custom:tenant"] == path.tenantId
+//	claims.email_verified == true && endsWith(lower(claims.email), "@example.com")
+//
+// Values are referenced as claims.<name> or claims["<name>"] (nested objects with more keys),
+// path.<name> (path parameters of REQUEST authorizers) and request.method, request.resource,
+// request.stage, request.region, request.account or request.api (parts of the method ARN, method and resource
+// are missing when there is no method ARN to evaluate against).
+// Literals are strings in double quotes, numbers, true, false and null. Operators are
+// ==, !=, !, && and || with parentheses. Functions are startsWith(s, prefix), endsWith(s, suffix),
+// contains(s or list, value), lower(s) and segment(path, index) returning the index-th path segment.
+// Values of different types are compared as formatted strings, so `"true"` equals true.
+// Missing values are null, conditions evaluating to null are false. Comparing a missing value with
+// anything but the null literal fails the evaluation, so `claims.tenant == path.tenantId` never holds
+// when both are missing.
+type Condition struct {
+	expression  string
+	root        conditionNode
+	usesRequest bool
+}
+
+// ConditionInput holds values conditions are evaluated against.
+type ConditionInput struct {
+	Claims         map[string]interface{}
+	PathParameters map[string]string
+	MethodARN      authorizer.MethodARN
+}
+
+// ConditionError describes invalid condition expression, it matches ErrInvalidCondition.
+type ConditionError struct {
+	Expression string
+	Position   int
+	Reason     string
+}
+
+func (e *ConditionError) Error() string {
+	return fmt.Sprintf("%s: %s at %d in %q", ErrInvalidCondition, e.Reason, e.Position, e.Expression)
+}
+
+// Is reports whether target is ErrInvalidCondition.
+func (e *ConditionError) Is(target error) bool {
+	return target == ErrInvalidCondition
+}
+
+// ParseCondition parses condition expression.
+func ParseCondition(expression string) (*Condition, error) {
+	if len(expression) > MaxConditionLength {
+		return nil, &ConditionError{Expression: expression[:32] + "...", Reason: "expression is too long"}
+	}
+
+	tokens, err := lexCondition(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &conditionParser{expression: expression, tokens: tokens}
+	root, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if token := parser.peek(); token.kind != tokenEnd {
+		return nil, parser.errorAt(token, fmt.Sprintf("unexpected %q", token.text))
+	}
+
+	return &Condition{expression: expression, root: root, usesRequest: parser.usesRequest}, nil
+}
+
+// UsesRequest tells whether the condition depends on the called method or path (path parameters,
+// request.method or request.resource). Such conditions are not cache-safe: with authorizer caching
+// the policy built for the first request is reused for other methods and paths.
+func (c *Condition) UsesRequest() bool {
+	return c.usesRequest
+}
+
+// String returns the condition expression.
+func (c *Condition) String() string {
+	return c.expression
+}
+
+// Evaluate evaluates the condition. Errors are returned for values of wrong types, e.g. `!claims.email`,
+// and for comparisons of missing claims or path parameters with anything but null.
+func (c *Condition) Evaluate(input ConditionInput) (bool, error) {
+	value, err := c.root.eval(&input)
+	if err != nil {
+		return false, err
+	}
+
+	return truth(value)
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+type conditionToken struct {
+	kind     tokenKind
+	text     string
+	value    interface{}
+	position int
+}
+
+var conditionOperators = []string{"==", "!=", "&&", "||", "!", "(", ")", "[", "]", ".", ","}
+
+func lexCondition(expression string) ([]conditionToken, error) {
+	var tokens []conditionToken
+
+	for i := 0; i < len(expression); {
+		char := rune(expression[i])
+		switch {
+		case unicode.IsSpace(char):
+			i++
+
+		case char == '"':
+			end := i + 1
+			for end < len(expression) && expression[end] != '"' {
+				if expression[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expression) {
+				return nil, &ConditionError{Expression: expression, Position: i, Reason: "unterminated string"}
+			}
+
+			value, err := strconv.Unquote(expression[i : end+1])
+			if err != nil {
+				return nil, &ConditionError{Expression: expression, Position: i, Reason: "invalid string"}
+			}
+			tokens = append(tokens, conditionToken{kind: tokenString, text: expression[i : end+1], value: value, position: i})
+			i = end + 1
+
+		case char == '-' || isDigit(expression[i]):
+			end := i + 1
+			for end < len(expression) && (isDigit(expression[end]) || expression[end] == '.') {
+				end++
+			}
+
+			value, err := strconv.ParseFloat(expression[i:end], 64)
+			if err != nil {
+				return nil, &ConditionError{Expression: expression, Position: i, Reason: "invalid number"}
+			}
+			tokens = append(tokens, conditionToken{kind: tokenNumber, text: expression[i:end], value: value, position: i})
+			i = end
+
+		case isIdentStart(expression[i]):
+			end := i + 1
+			for end < len(expression) && (isIdentStart(expression[end]) || isDigit(expression[end])) {
+				end++
+			}
+			tokens = append(tokens, conditionToken{kind: tokenIdent, text: expression[i:end], position: i})
+			i = end
+
+		default:
+			operator := ""
+			for _, candidate := range conditionOperators {
+				if strings.HasPrefix(expression[i:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, &ConditionError{Expression: expression, Position: i, Reason: fmt.Sprintf("unexpected %q", char)}
+			}
+			tokens = append(tokens, conditionToken{kind: tokenPunct, text: operator, position: i})
+			i += len(operator)
+		}
+	}
+
+	return append(tokens, conditionToken{kind: tokenEnd, position: len(expression)}), nil
+}
+
+func isDigit(char byte) bool {
+	return '0' <= char && char <= '9'
+}
+
+func isIdentStart(char byte) bool {
+	return char == '_' || 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z'
+}
@@ -0,0 +1,491 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type conditionNode interface {
+	eval(input *ConditionInput) (interface{}, error)
+}
+
+type conditionParser struct {
+	expression  string
+	tokens      []conditionToken
+	position    int
+	depth       int
+	usesRequest bool
+}
+
+func (p *conditionParser) peek() conditionToken {
+	return p.tokens[p.position]
+}
+
+func (p *conditionParser) next() conditionToken {
+	token := p.tokens[p.position]
+	if token.kind != tokenEnd {
+		p.position++
+	}
+	return token
+}
+
+func (p *conditionParser) accept(punct string) bool {
+	if token := p.peek(); token.kind == tokenPunct && token.text == punct {
+		p.position++
+		return true
+	}
+	return false
+}
+
+func (p *conditionParser) expect(punct string) error {
+	if !p.accept(punct) {
+		token := p.peek()
+		return p.errorAt(token, fmt.Sprintf("expected %q", punct))
+	}
+	return nil
+}
+
+func (p *conditionParser) errorAt(token conditionToken, reason string) error {
+	return &ConditionError{Expression: p.expression, Position: token.position, Reason: reason}
+}
+
+func (p *conditionParser) parseOr() (conditionNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{and: false, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *conditionParser) parseAnd() (conditionNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{and: true, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *conditionParser) parseNot() (conditionNode, error) {
+	if p.accept("!") {
+		operand, err := p.nested(p.parseNot)
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *conditionParser) parseComparison() (conditionNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.accept("==") {
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &equalNode{left: left, right: right}, nil
+	}
+
+	if p.accept("!=") {
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: &equalNode{left: left, right: right}}, nil
+	}
+
+	return left, nil
+}
+
+func (p *conditionParser) parsePrimary() (conditionNode, error) {
+	token := p.next()
+	switch token.kind {
+	case tokenString, tokenNumber:
+		return &literalNode{value: token.value}, nil
+
+	case tokenIdent:
+		switch token.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+
+		if p.peek().kind == tokenPunct && p.peek().text == "(" {
+			return p.parseCall(token)
+		}
+		return p.parseReference(token)
+
+	case tokenPunct:
+		if token.text == "(" {
+			node, err := p.nested(p.parseOr)
+			if err != nil {
+				return nil, err
+			}
+			return node, p.expect(")")
+		}
+	}
+
+	if token.kind == tokenEnd {
+		return nil, p.errorAt(token, "unexpected end")
+	}
+	return nil, p.errorAt(token, fmt.Sprintf("unexpected %q", token.text))
+}
+
+// nested parses a nested expression, depth is limited so expressions can not exhaust the stack.
+func (p *conditionParser) nested(parse func() (conditionNode, error)) (conditionNode, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+
+	if p.depth > MaxConditionDepth {
+		return nil, p.errorAt(p.peek(), "expression is nested too deep")
+	}
+
+	return parse()
+}
+
+func (p *conditionParser) parseCall(name conditionToken) (conditionNode, error) {
+	function, ok := conditionFunctions[name.text]
+	if !ok {
+		return nil, p.errorAt(name, fmt.Sprintf("unknown function %q", name.text))
+	}
+	p.next()
+
+	var args []conditionNode
+	if !p.accept(")") {
+		for {
+			arg, err := p.nested(p.parseOr)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(args) != function.arity {
+		return nil, p.errorAt(name, fmt.Sprintf("%s expects %d arguments", name.text, function.arity))
+	}
+
+	return &callNode{function: function.call, args: args}, nil
+}
+
+func (p *conditionParser) parseReference(root conditionToken) (conditionNode, error) {
+	if root.text != "claims" && root.text != "path" && root.text != "request" {
+		return nil, p.errorAt(root, fmt.Sprintf("unknown name %q", root.text))
+	}
+
+	reference := &referenceNode{root: root.text}
+	for {
+		if p.accept(".") {
+			key := p.next()
+			if key.kind != tokenIdent {
+				return nil, p.errorAt(key, "expected name")
+			}
+			reference.keys = append(reference.keys, key.text)
+		} else if p.accept("[") {
+			key := p.next()
+			if key.kind != tokenString {
+				return nil, p.errorAt(key, "expected string")
+			}
+			reference.keys = append(reference.keys, key.value.(string))
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		} else {
+			break
+		}
+	}
+
+	if len(reference.keys) == 0 {
+		return nil, p.errorAt(root, fmt.Sprintf("%s needs a name", root.text))
+	}
+
+	if root.text != "claims" && len(reference.keys) > 1 {
+		return nil, p.errorAt(root, fmt.Sprintf("%s values have no fields", root.text))
+	}
+
+	if root.text == "request" && requestValues[reference.keys[0]] == nil {
+		return nil, p.errorAt(root, fmt.Sprintf("unknown request value %q", reference.keys[0]))
+	}
+
+	if root.text == "path" || root.text == "request" && !cacheSafeRequestValues[reference.keys[0]] {
+		p.usesRequest = true
+	}
+
+	return reference, nil
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(input *ConditionInput) (interface{}, error) {
+	return n.value, nil
+}
+
+var requestValues = map[string]func(input *ConditionInput) string{
+	"method":   func(input *ConditionInput) string { return input.MethodARN.Method },
+	"resource": func(input *ConditionInput) string { return input.MethodARN.Resource },
+	"stage":    func(input *ConditionInput) string { return input.MethodARN.Stage },
+	"region":   func(input *ConditionInput) string { return input.MethodARN.Region },
+	"account":  func(input *ConditionInput) string { return input.MethodARN.AccountID },
+	"api":      func(input *ConditionInput) string { return input.MethodARN.APIID },
+}
+
+// cacheSafeRequestValues are the same for all requests sharing a cached authorizer result.
+var cacheSafeRequestValues = map[string]bool{
+	"stage":   true,
+	"region":  true,
+	"account": true,
+	"api":     true,
+}
+
+type referenceNode struct {
+	root string
+	keys []string
+}
+
+func (n *referenceNode) eval(input *ConditionInput) (interface{}, error) {
+	switch n.root {
+	case "path":
+		value, ok := input.PathParameters[n.keys[0]]
+		if !ok {
+			return nil, nil
+		}
+		return value, nil
+
+	case "request":
+		value := requestValues[n.keys[0]](input)
+		if value == "" {
+			return nil, nil
+		}
+		return value, nil
+	}
+
+	var value interface{} = input.Claims
+	for _, key := range n.keys {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		value = object[key]
+	}
+
+	return value, nil
+}
+
+type logicalNode struct {
+	and         bool
+	left, right conditionNode
+}
+
+func (n *logicalNode) eval(input *ConditionInput) (interface{}, error) {
+	left, err := evalTruth(n.left, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if left != n.and {
+		return left, nil
+	}
+
+	return evalTruth(n.right, input)
+}
+
+type notNode struct {
+	operand conditionNode
+}
+
+func (n *notNode) eval(input *ConditionInput) (interface{}, error) {
+	value, err := evalTruth(n.operand, input)
+	if err != nil {
+		return nil, err
+	}
+
+	return !value, nil
+}
+
+type equalNode struct {
+	left, right conditionNode
+}
+
+func (n *equalNode) eval(input *ConditionInput) (interface{}, error) {
+	left, err := n.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := n.right.eval(input)
+	if err != nil {
+		return nil, err
+	}
+
+	// Comparing with null tests whether a value is missing, any other comparison of a missing
+	// value is an error, so e.g. a missing claim never equals a missing path parameter.
+	if isNullLiteral(n.left) || isNullLiteral(n.right) {
+		return left == nil && right == nil, nil
+	}
+
+	if left == nil || right == nil {
+		return nil, errMissingValue
+	}
+
+	return conditionEqual(left, right), nil
+}
+
+var errMissingValue = errors.New("condition compares a missing value")
+
+func isNullLiteral(node conditionNode) bool {
+	literal, ok := node.(*literalNode)
+	return ok && literal.value == nil
+}
+
+type callNode struct {
+	function func(args []interface{}) (interface{}, error)
+	args     []conditionNode
+}
+
+func (n *callNode) eval(input *ConditionInput) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+
+	return n.function(args)
+}
+
+type conditionFunction struct {
+	arity int
+	call  func(args []interface{}) (interface{}, error)
+}
+
+var conditionFunctions = map[string]conditionFunction{
+	"startsWith": {arity: 2, call: stringsFunction(strings.HasPrefix)},
+	"endsWith":   {arity: 2, call: stringsFunction(strings.HasSuffix)},
+	"contains": {arity: 2, call: func(args []interface{}) (interface{}, error) {
+		if list, ok := args[0].([]interface{}); ok {
+			for _, element := range list {
+				if conditionEqual(element, args[1]) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return stringsFunction(strings.Contains)(args)
+	}},
+	"lower": {arity: 1, call: func(args []interface{}) (interface{}, error) {
+		text, ok := args[0].(string)
+		if !ok {
+			return nil, nil
+		}
+		return strings.ToLower(text), nil
+	}},
+	"segment": {arity: 2, call: func(args []interface{}) (interface{}, error) {
+		path, ok := args[0].(string)
+		index, isNumber := args[1].(float64)
+		if !ok || !isNumber {
+			return nil, nil
+		}
+
+		segments := strings.Split(strings.Trim(path, "/"), "/")
+		if index < 0 || int(index) >= len(segments) || float64(int(index)) != index {
+			return nil, nil
+		}
+		return segments[int(index)], nil
+	}},
+}
+
+// stringsFunction calls f with two string arguments, the result is false when any argument is not a string.
+func stringsFunction(f func(s, value string) bool) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		text, ok := args[0].(string)
+		value, isString := args[1].(string)
+		if !ok || !isString {
+			return false, nil
+		}
+		return f(text, value), nil
+	}
+}
+
+func evalTruth(node conditionNode, input *ConditionInput) (bool, error) {
+	value, err := node.eval(input)
+	if err != nil {
+		return false, err
+	}
+
+	return truth(value)
+}
+
+// truth converts value to boolean, null is false and values other than booleans are errors.
+func truth(value interface{}) (bool, error) {
+	switch value := value.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return value, nil
+	}
+
+	return false, fmt.Errorf("condition value %v is not a boolean", value)
+}
+
+// conditionEqual compares values of the same scalar type directly and other values as formatted strings.
+// Missing values are not equal to anything.
+func conditionEqual(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return false
+	}
+
+	switch left := left.(type) {
+	case string:
+		if right, ok := right.(string); ok {
+			return left == right
+		}
+	case bool:
+		if right, ok := right.(bool); ok {
+			return left == right
+		}
+	case float64:
+		if right, ok := right.(float64); ok {
+			return left == right
+		}
+	}
+
+	return fmt.Sprint(left) == fmt.Sprint(right)
+}
@@ -0,0 +1,134 @@
+package builder
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nordcloud/cognito-authorizer/pkg/authorizer"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestConditionInput() ConditionInput {
+	return ConditionInput{
+		Claims: map[string]interface{}{
+			"email":          "John.Doe@Example.com",
+			"email_verified": true,
+			"custom:tenant":  "acme",
+			"custom:seats":   "5",
+			"cognito:groups": []interface{}{"admins", "users"},
+			"address":        map[string]interface{}{"country": "FI"},
+			"auth_time":      float64(1500000000),
+		},
+		PathParameters: map[string]string{"tenantId": "acme"},
+		MethodARN: authorizer.MethodARN{
+			Partition: "aws",
+			Region:    "eu-west-1",
+			AccountID: "123456789012",
+			APIID:     "api-id",
+			Stage:     "prod",
+			Method:    "GET",
+			Resource:  "/tenants/acme/items",
+		},
+	}
+}
+
+func TestConditionEvaluate(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{expression: `claims["custom:tenant"] == path.tenantId`, want: true},
+		{expression: `claims["custom:tenant"] != path.tenantId`, want: false},
+		{expression: `claims.email_verified == true && endsWith(lower(claims.email), "@example.com")`, want: true},
+		{expression: `claims.email_verified && endsWith(claims.email, "@example.com")`, want: false},
+		{expression: `claims.email_verified == "true"`, want: true},
+		{expression: `claims["custom:seats"] == 5`, want: true},
+		{expression: `claims.auth_time == 1500000000`, want: true},
+		{expression: `contains(claims["cognito:groups"], "admins")`, want: true},
+		{expression: `contains(claims.email, "Doe")`, want: true},
+		{expression: `claims.address.country == "FI"`, want: true},
+		{expression: `claims["address"]["country"] == "SE" || request.method == "GET"`, want: true},
+		{expression: `!(request.stage == "prod") || request.account == "123456789012"`, want: true},
+		{expression: `segment(request.resource, 1) == claims["custom:tenant"]`, want: true},
+		{expression: `segment(request.resource, 5) == null`, want: true},
+		{expression: `startsWith(request.resource, "/tenants/") && request.api == "api-id" && request.region == "eu-west-1"`, want: true},
+		{expression: `claims.missing == null`, want: true},
+		{expression: `claims.missing`, want: false},
+		{expression: `claims.email.domain == null`, want: true},
+		{expression: `startsWith(claims.auth_time, "15")`, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			condition, err := ParseCondition(tt.expression)
+			assert.Nil(t, err)
+
+			holds, err := condition.Evaluate(createTestConditionInput())
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, holds)
+		})
+	}
+}
+
+func TestConditionEvaluateNotBoolean(t *testing.T) {
+	for _, expression := range []string{`claims.email`, `!claims.email`, `claims.email_verified && claims.email`} {
+		condition, _ := ParseCondition(expression)
+
+		_, err := condition.Evaluate(createTestConditionInput())
+
+		assert.NotNil(t, err, expression)
+	}
+}
+
+func TestConditionEvaluateMissingValue(t *testing.T) {
+	for _, expression := range []string{
+		`path.other == claims.missing`,
+		`claims.missing != path.tenantId`,
+		`claims["custom:tenant"] == path.other`,
+		`segment(request.resource, 5) == "items"`,
+	} {
+		condition, _ := ParseCondition(expression)
+
+		_, err := condition.Evaluate(createTestConditionInput())
+
+		assert.NotNil(t, err, expression)
+	}
+}
+
+func TestParseConditionInvalid(t *testing.T) {
+	for _, expression := range []string{
+		``,
+		`claims`,
+		`claims.`,
+		`claims[1] == "a"`,
+		`claims["a" == "b"`,
+		`token.sub == "a"`,
+		`path.a.b == "c"`,
+		`request.header == "a"`,
+		`unknown(claims.email)`,
+		`endsWith(claims.email)`,
+		`claims.email == "a`,
+		`claims.email = "a"`,
+		`claims.email == "a" &&`,
+		`(claims.email == "a"`,
+		`claims.email == "a")`,
+		`claims.a == 1.2.3`,
+		`claims.a == -`,
+		`claims.é == "a"`,
+		strings.Repeat("(", MaxConditionDepth+1) + "true" + strings.Repeat(")", MaxConditionDepth+1),
+		strings.Repeat("!", MaxConditionDepth+1) + "true",
+		`claims.a == "` + strings.Repeat("a", MaxConditionLength) + `"`,
+	} {
+		_, err := ParseCondition(expression)
+
+		assert.True(t, errors.Is(err, ErrInvalidCondition), expression)
+	}
+}
+
+func TestConditionError(t *testing.T) {
+	_, err := ParseCondition(`claims.email == "a" && token.sub`)
+
+	assert.Equal(t, `condition is invalid: unknown name "token" at 23 in "claims.email == \"a\" && token.sub"`, err.Error())
+}
@@ -1,6 +1,7 @@
 package builder
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/nordcloud/cognito-authorizer/pkg/authorizer"
@@ -15,32 +16,27 @@ type DefaultContextBuilder struct {
 
 // BuildContext builds a context that is passed to resource server.
 func (c *DefaultContextBuilder) BuildContext(encodedToken string) (map[string]interface{}, error) {
-	baseClaims := authorizer.BaseTokenClaims{}
-	err := authorizer.GetBaseClaims(encodedToken, c.Context.DecryptionKeys, &baseClaims)
+	token, err := authorizer.NewVerifier(c.Context).Verify(encodedToken)
 	if err != nil {
-		log.Error("Failed to get base claims.")
+		log.Error("Failed to verify token.")
 		return map[string]interface{}{}, err
 	}
 
-	if baseClaims.TokenUse == "access" {
-		accessClaims := authorizer.AccessTokenClaims{}
-		err := authorizer.GetAccessClaims(encodedToken, c.Context.DecryptionKeys, &accessClaims)
-		if err != nil {
-			return map[string]interface{}{}, err
-		}
+	return c.BuildContextForToken(token)
+}
 
-		return c.buildContextForAccessClaims(accessClaims)
+// BuildContextForToken builds a context that is passed to resource server from verified token.
+func (c *DefaultContextBuilder) BuildContextForToken(token *authorizer.VerifiedToken) (map[string]interface{}, error) {
+	if token.Access != nil {
+		return c.buildContextForAccessClaims(*token.Access)
 	}
 
-	idClaims := authorizer.IDTokenClaims{}
-	err = authorizer.GetIDClaims(encodedToken, c.Context.DecryptionKeys, &idClaims)
-
-	if err != nil {
+	if token.ID == nil {
 		log.Error("Failed to get id claims.")
-		return map[string]interface{}{}, err
+		return map[string]interface{}{}, errors.New("token has no id claims")
 	}
 
-	return c.buildContextForIDClaims(idClaims)
+	return c.buildContextForIDClaims(*token.ID)
 }
 
 func (c *DefaultContextBuilder) buildContextForAccessClaims(claims authorizer.AccessTokenClaims) (map[string]interface{}, error) {
@@ -2,7 +2,6 @@ package builder
 
 import (
 	"errors"
-	"fmt"
 	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -13,8 +12,8 @@ import (
 type PolicyEffect string
 
 const (
-	allow PolicyEffect = "allow"
-	deny  PolicyEffect = "deny"
+	allow PolicyEffect = "Allow"
+	deny  PolicyEffect = "Deny"
 )
 
 // DefaultPolicyBuilder Implements Policy builder interface.
@@ -27,30 +26,40 @@ type DefaultPolicyBuilder struct {
 
 // BuildPolicy builds proper apigw policy based on scope from claims.
 func (p *DefaultPolicyBuilder) BuildPolicy(encodedToken string) (events.APIGatewayCustomAuthorizerPolicy, error) {
-	baseClaims := authorizer.BaseTokenClaims{}
-	err := authorizer.GetBaseClaims(encodedToken, p.Context.DecryptionKeys, &baseClaims)
+	token, err := authorizer.NewVerifier(p.Context).Verify(encodedToken)
 	if err != nil {
-		log.Error("Failed to get base claims.")
+		log.Error("Failed to verify token.")
 		return events.APIGatewayCustomAuthorizerPolicy{}, err
 	}
 
-	var resources []string
+	return p.BuildPolicyForToken(token)
+}
 
-	log.WithField("token_use", baseClaims.TokenUse).Debug("Token type.")
-	if baseClaims.TokenUse == "access" {
-		accessClaims := authorizer.AccessTokenClaims{}
-		err = authorizer.GetAccessClaims(encodedToken, p.Context.DecryptionKeys, &accessClaims)
-		if err != nil {
-			log.Error("Failed to get access claims.")
-			return events.APIGatewayCustomAuthorizerPolicy{}, err
-		}
+// BuildPolicyForToken builds proper apigw policy based on scope from verified token claims.
+func (p *DefaultPolicyBuilder) BuildPolicyForToken(token *authorizer.VerifiedToken) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	return p.buildPolicy(token, authorizer.NewAPIARN(p.Region, p.Context.ApplicationID, p.Context.Stage))
+}
 
-		resources = p.buildResourcesForAccessClaims(accessClaims)
+// BuildPolicyForRequest builds proper apigw policy scoped to the account of the request method ARN.
+func (p *DefaultPolicyBuilder) BuildPolicyForRequest(request *authorizer.AuthorizerRequest) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	api, err := requestAPIARN(request, authorizer.NewAPIARN(p.Region, p.Context.ApplicationID, p.Context.Stage))
+	if err != nil {
+		return events.APIGatewayCustomAuthorizerPolicy{}, err
+	}
+
+	return p.buildPolicy(request.Token, api)
+}
 
-	} else if baseClaims.TokenUse == "id" {
+func (p *DefaultPolicyBuilder) buildPolicy(token *authorizer.VerifiedToken, api authorizer.MethodARN) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	var resources []string
+
+	log.WithField("token_use", token.Base.TokenUse).Debug("Token type.")
+	if token.Access != nil {
+		resources = p.buildResourcesForAccessClaims(*token.Access, api)
+	} else if token.ID != nil {
 		resources = []string{}
 	} else {
-		log.WithField("token_use", baseClaims.TokenUse).Error("Unkown token use. Aborting")
+		log.WithField("token_use", token.Base.TokenUse).Error("Unkown token use. Aborting")
 		return events.APIGatewayCustomAuthorizerPolicy{}, errors.New("unknown token use")
 	}
 
@@ -68,10 +77,26 @@ func (p *DefaultPolicyBuilder) BuildPolicy(encodedToken string) (events.APIGatew
 	return policy, nil
 }
 
-func (p *DefaultPolicyBuilder) buildResourcesForAccessClaims(claims authorizer.AccessTokenClaims) []string {
+func (p *DefaultPolicyBuilder) buildResourcesForAccessClaims(claims authorizer.AccessTokenClaims, api authorizer.MethodARN) []string {
 	scopes := strings.Split(claims.Scope, " ")
 	log.WithField("scopes", scopes).Info("Generating access for the scope")
-	return []string{fmt.Sprintf(
-		"arn:aws:execute-api:%s:*:%s/%s/*/*", p.Region, p.Context.ApplicationID, p.Context.Stage,
-	)}
+	return []string{api.WithRoute("*", "/*").String()}
+}
+
+// requestAPIARN returns api with partition and account of the request method ARN, api when the request has no method ARN.
+// API and stage always come from api, so a policy never grants another API or stage sharing the authorizer.
+func requestAPIARN(request *authorizer.AuthorizerRequest, api authorizer.MethodARN) (authorizer.MethodARN, error) {
+	if request.MethodArn == "" {
+		return api, nil
+	}
+
+	arn, err := authorizer.ParseMethodARN(request.MethodArn)
+	if err != nil {
+		log.WithField("methodArn", request.MethodArn).Error("Failed to parse method ARN.")
+		return authorizer.MethodARN{}, err
+	}
+
+	api.Partition = arn.Partition
+	api.AccountID = arn.AccountID
+	return api, nil
 }
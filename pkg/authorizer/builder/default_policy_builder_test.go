@@ -0,0 +1,62 @@
+package builder
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nordcloud/cognito-authorizer/pkg/authorizer"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestDefaultPolicyBuilder() *DefaultPolicyBuilder {
+	return &DefaultPolicyBuilder{
+		Context: &authorizer.Context{
+			ApplicationID: "api-id",
+			Stage:         "prod",
+		},
+		Region: "eu-west-1",
+	}
+}
+
+func TestDefaultPolicyBuilderBuildPolicyForToken(t *testing.T) {
+	token := &authorizer.VerifiedToken{Access: &authorizer.AccessTokenClaims{Scope: "items.read"}}
+
+	policy, err := createTestDefaultPolicyBuilder().BuildPolicyForToken(token)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []events.IAMPolicyStatement{
+		allowStatement("arn:aws:execute-api:eu-west-1:*:api-id/prod/*/*"),
+	}, policy.Statement)
+}
+
+func TestDefaultPolicyBuilderBuildPolicyForRequest(t *testing.T) {
+	for _, methodARN := range []string{
+		"arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/items/1",
+		"arn:aws:execute-api:eu-west-1:123456789012:other-api/prod/GET/items/1",
+		"arn:aws:execute-api:eu-west-1:123456789012:api-id/dev/GET/items/1",
+	} {
+		request := &authorizer.AuthorizerRequest{
+			Token:     &authorizer.VerifiedToken{Access: &authorizer.AccessTokenClaims{Scope: "items.read"}},
+			MethodArn: methodARN,
+		}
+
+		policy, err := createTestDefaultPolicyBuilder().BuildPolicyForRequest(request)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []events.IAMPolicyStatement{
+			allowStatement("arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/*/*"),
+		}, policy.Statement, methodARN)
+	}
+}
+
+func TestDefaultPolicyBuilderInvalidMethodARN(t *testing.T) {
+	request := &authorizer.AuthorizerRequest{
+		Token:     &authorizer.VerifiedToken{Access: &authorizer.AccessTokenClaims{}},
+		MethodArn: "invalid",
+	}
+
+	_, err := createTestDefaultPolicyBuilder().BuildPolicyForRequest(request)
+
+	assert.True(t, errors.Is(err, authorizer.ErrInvalidMethodARN))
+}
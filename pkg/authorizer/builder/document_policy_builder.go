@@ -0,0 +1,278 @@
+package builder
+
+import (
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nordcloud/cognito-authorizer/pkg/authorizer"
+	log "github.com/sirupsen/logrus"
+)
+
+// DocumentPolicyBuilder implements the PolicyBuilder interface with rules of a policy document.
+// It emits an Allow statement for every route of rules matching the token and API stage. Rules with conditions
+// using the request (see Condition.UsesRequest) allow only the request method ARN.
+// With document DenyUnmatched routes of other rules are denied explicitly. Tokens without
+// any allowed route are denied with authorizer.AccessDeniedError.
+//
+// With CacheSafe the policy does not depend on the called method or path, so it stays correct when
+// API Gateway caches the authorizer result: allow rules with conditions using the request (see Condition.UsesRequest)
+// are skipped, routes of such deny rules are denied regardless of the condition and tokens without any allowed
+// route are denied on the whole API stage.
+type DocumentPolicyBuilder struct {
+	Context   *authorizer.Context
+	Document  *PolicyDocument
+	CacheSafe bool
+}
+
+// NewDocumentPolicyBuilder validates the document and creates a builder for it.
+func NewDocumentPolicyBuilder(context *authorizer.Context, document *PolicyDocument) (*DocumentPolicyBuilder, error) {
+	err := document.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &DocumentPolicyBuilder{Context: context, Document: document}, nil
+}
+
+// BuildPolicy builds proper apigw policy based on document rules.
+func (p *DocumentPolicyBuilder) BuildPolicy(encodedToken string) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	token, err := authorizer.NewVerifier(p.Context).Verify(encodedToken)
+	if err != nil {
+		log.Error("Failed to verify token.")
+		return events.APIGatewayCustomAuthorizerPolicy{}, err
+	}
+
+	return p.BuildPolicyForToken(token)
+}
+
+// BuildPolicyForToken builds proper apigw policy based on document rules and verified token claims.
+func (p *DocumentPolicyBuilder) BuildPolicyForToken(token *authorizer.VerifiedToken) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	return p.buildPolicy(token, authorizer.NewAPIARN(p.Context.Region, p.Context.ApplicationID, p.Context.Stage), nil)
+}
+
+// BuildPolicyForRequest builds proper apigw policy based on document rules, scoped to the account of the request method ARN.
+// Rule conditions get the request method ARN and path parameters.
+func (p *DocumentPolicyBuilder) BuildPolicyForRequest(request *authorizer.AuthorizerRequest) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	api, err := requestAPIARN(request, authorizer.NewAPIARN(p.Context.Region, p.Context.ApplicationID, p.Context.Stage))
+	if err != nil {
+		return events.APIGatewayCustomAuthorizerPolicy{}, err
+	}
+
+	if request.MethodArn == "" {
+		return p.buildPolicy(request.Token, api, nil)
+	}
+
+	input := ConditionInput{
+		Claims:         request.Token.Claims,
+		PathParameters: request.PathParameters,
+	}
+	input.MethodARN, _ = authorizer.ParseMethodARN(request.MethodArn)
+
+	return p.buildPolicy(request.Token, api, &input)
+}
+
+// buildPolicy builds the policy for the token, request is nil when there is no request to evaluate conditions against.
+// Without a request, request.method and request.resource are missing, so comparing them fails.
+func (p *DocumentPolicyBuilder) buildPolicy(token *authorizer.VerifiedToken, api authorizer.MethodARN, request *ConditionInput) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	input := request
+	if input == nil {
+		stage := api
+		stage.Method, stage.Resource = "", ""
+		input = &ConditionInput{Claims: token.Claims, MethodARN: stage}
+	}
+
+	statements := newRouteStatements(api)
+	for _, rule := range p.Document.Rules {
+		if !ruleHasStage(rule, api.Stage) {
+			continue
+		}
+
+		if p.CacheSafe && ruleUsesRequest(rule) {
+			// The condition can not hold for all requests the policy is cached for: allow rules are skipped,
+			// deny rules deny their routes whenever the rest of the rule matches.
+			if isDenyRule(rule) && ruleMatchesToken(rule, token) {
+				log.WithField("condition", rule.Condition).Warn("Denying routes of rule using the request in cache-safe policy.")
+				statements.deny(rule.Routes)
+			} else {
+				log.WithField("condition", rule.Condition).Debug("Skipping rule using the request in cache-safe policy.")
+			}
+			continue
+		}
+
+		matches := ruleMatches(rule, token, input)
+		if isDenyRule(rule) {
+			if matches {
+				statements.deny(rule.Routes)
+			}
+			continue
+		}
+
+		if matches && ruleUsesRequest(rule) {
+			// The condition holds for this request only, other paths of the routes were not evaluated.
+			if request != nil {
+				statements.allowRequest(rule.Routes, request.MethodARN)
+			}
+			continue
+		}
+
+		statements.add(rule.Routes, matches)
+	}
+
+	policy, ok := statements.policy(p.Document.DenyUnmatched)
+	if !ok {
+		log.WithField("sub", token.Base.Subject).Error("No routes allowed by policy document.")
+		denied := &authorizer.AccessDeniedError{Reason: "no routes allowed by policy document"}
+		if p.CacheSafe {
+			denied.Resource = []string{api.WithRoute("*", "/*").String()}
+		}
+		return events.APIGatewayCustomAuthorizerPolicy{}, denied
+	}
+
+	return policy, nil
+}
+
+func ruleHasStage(rule PolicyRule, stage string) bool {
+	if len(rule.Stages) == 0 {
+		return true
+	}
+
+	for _, ruleStage := range rule.Stages {
+		if ruleStage == stage {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ruleMatches tells whether the token has one of rule scopes, one of rule groups, all rule claims
+// and satisfies rule condition.
+func ruleMatches(rule PolicyRule, token *authorizer.VerifiedToken, input *ConditionInput) bool {
+	if !ruleMatchesToken(rule, token) {
+		return false
+	}
+
+	if rule.Condition == "" {
+		return true
+	}
+
+	return conditionHolds(rule, input)
+}
+
+// ruleMatchesToken tells whether the token has one of rule scopes, one of rule groups and all rule claims.
+func ruleMatchesToken(rule PolicyRule, token *authorizer.VerifiedToken) bool {
+	if len(rule.Scopes) > 0 {
+		var scopes []string
+		if token.Access != nil {
+			scopes = strings.Fields(token.Access.Scope)
+		}
+
+		if !hasAnyScope(scopes, rule.Scopes) {
+			return false
+		}
+	}
+
+	if len(rule.Groups) > 0 && !hasAnyGroup(tokenGroups(token), rule.Groups) {
+		return false
+	}
+
+	for name, value := range rule.Claims {
+		if !claimHasValue(token.Claims[name], value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// conditionHolds evaluates rule condition. Conditions failing to parse or evaluate do not hold for allow
+// rules, but hold for deny rules, so an unexpected claim value never lifts a deny.
+func conditionHolds(rule PolicyRule, input *ConditionInput) bool {
+	failed := isDenyRule(rule)
+
+	condition, err := ruleCondition(rule)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"condition": rule.Condition,
+			"error":     err,
+			"holds":     failed,
+		}).Error("Failed to parse rule condition.")
+		return failed
+	}
+
+	holds, err := condition.Evaluate(*input)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"condition": condition.String(),
+			"error":     err,
+			"holds":     failed,
+		}).Warn("Failed to evaluate rule condition.")
+		return failed
+	}
+
+	return holds
+}
+
+func isDenyRule(rule PolicyRule) bool {
+	return strings.EqualFold(rule.Effect, string(deny))
+}
+
+// ruleUsesRequest tells whether rule condition depends on the called method or path, invalid conditions do not.
+func ruleUsesRequest(rule PolicyRule) bool {
+	if rule.Condition == "" {
+		return false
+	}
+
+	condition, err := ruleCondition(rule)
+	return err == nil && condition.UsesRequest()
+}
+
+// ruleCondition returns the condition compiled by PolicyDocument.Validate or parses it.
+func ruleCondition(rule PolicyRule) (*Condition, error) {
+	if rule.condition != nil {
+		return rule.condition, nil
+	}
+
+	return ParseCondition(rule.Condition)
+}
+
+func hasAnyScope(scopes, ruleScopes []string) bool {
+	for _, ruleScope := range ruleScopes {
+		if hasScope(scopes, ruleScope) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyGroup(groups, ruleGroups []string) bool {
+	for _, group := range groups {
+		for _, ruleGroup := range ruleGroups {
+			if group == ruleGroup {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// claimHasValue compares string form of claim with value, list claims match when one of their elements does.
+// Values that are not strings, numbers or booleans never match.
+func claimHasValue(claim interface{}, value interface{}) bool {
+	if elements, ok := claim.([]interface{}); ok {
+		for _, element := range elements {
+			if claimHasValue(element, value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	claimValue, ok := claimString(claim)
+	if !ok {
+		return false
+	}
+
+	ruleValue, ok := claimString(value)
+	return ok && claimValue == ruleValue
+}
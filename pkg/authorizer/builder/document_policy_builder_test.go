@@ -0,0 +1,336 @@
+package builder
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/nordcloud/cognito-authorizer/pkg/authorizer"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestDocumentPolicyBuilder(stage string) *DocumentPolicyBuilder {
+	builder, _ := NewDocumentPolicyBuilder(&authorizer.Context{
+		Region:        "eu-west-1",
+		ApplicationID: "api-id",
+		Stage:         stage,
+	}, createTestPolicyDocument())
+
+	return builder
+}
+
+func TestDocumentPolicyBuilderScopes(t *testing.T) {
+	token := &authorizer.VerifiedToken{
+		Access: &authorizer.AccessTokenClaims{Scope: "https://api.example.com/items.read"},
+	}
+
+	policy, err := createTestDocumentPolicyBuilder("prod").BuildPolicyForToken(token)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []events.IAMPolicyStatement{
+		allowStatement("arn:aws:execute-api:eu-west-1:*:api-id/prod/GET/items/*"),
+		{
+			Action:   []string{"execute-api:Invoke"},
+			Effect:   "Deny",
+			Resource: []string{"arn:aws:execute-api:eu-west-1:*:api-id/prod/POST/items"},
+		},
+	}, policy.Statement)
+}
+
+func TestDocumentPolicyBuilderGroupsAndStages(t *testing.T) {
+	token := &authorizer.VerifiedToken{
+		ID: &authorizer.IDTokenClaims{Groups: []string{"admins"}},
+	}
+
+	policy, err := createTestDocumentPolicyBuilder("dev").BuildPolicyForToken(token)
+
+	assert.Nil(t, err)
+	assert.Equal(t, allowStatement("arn:aws:execute-api:eu-west-1:*:api-id/dev/*/*"), policy.Statement[0])
+
+	_, err = createTestDocumentPolicyBuilder("prod").BuildPolicyForToken(token)
+
+	assert.True(t, errors.Is(err, authorizer.ErrAccessDenied))
+}
+
+func TestDocumentPolicyBuilderClaims(t *testing.T) {
+	request := &authorizer.AuthorizerRequest{
+		Token: &authorizer.VerifiedToken{
+			ID:     &authorizer.IDTokenClaims{},
+			Claims: map[string]interface{}{"custom:tenant_id": "acme"},
+		},
+		MethodArn: "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/POST/items",
+	}
+
+	policy, err := createTestDocumentPolicyBuilder("prod").BuildPolicyForRequest(request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, allowStatement("arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/POST/items"), policy.Statement[0])
+}
+
+func TestClaimHasValue(t *testing.T) {
+	assert.True(t, claimHasValue("acme", "acme"))
+	assert.True(t, claimHasValue([]interface{}{"admins", "users"}, "users"))
+	assert.True(t, claimHasValue(true, "true"))
+	assert.True(t, claimHasValue("true", true))
+	assert.True(t, claimHasValue(float64(1234567890), 1234567890))
+	assert.False(t, claimHasValue(nil, ""))
+	assert.False(t, claimHasValue(map[string]interface{}{}, "map[]"))
+	assert.False(t, claimHasValue("other", "acme"))
+}
+
+func TestNewDocumentPolicyBuilderInvalid(t *testing.T) {
+	_, err := NewDocumentPolicyBuilder(&authorizer.Context{}, &PolicyDocument{Version: 1})
+
+	assert.True(t, errors.Is(err, ErrInvalidPolicyDocument))
+}
+
+const testConditionPolicyDocument = `
+version: 1
+rules:
+  - condition: 'claims["custom:tenant"] == path.tenantId'
+    routes:
+      - {method: "*", resource: /tenants/*}
+  - groups: [admins]
+    routes:
+      - {method: "*", resource: /tenants/*}
+      - {method: "*", resource: /admin/*}
+  - condition: 'claims.email_verified != true'
+    effect: deny
+    routes:
+      - {method: "*", resource: /admin/*}
+`
+
+func createTestConditionPolicyBuilder(t *testing.T) *DocumentPolicyBuilder {
+	document, err := ParsePolicyDocument([]byte(testConditionPolicyDocument))
+	assert.Nil(t, err)
+
+	return &DocumentPolicyBuilder{
+		Context:  &authorizer.Context{Region: "eu-west-1", ApplicationID: "api-id", Stage: "prod"},
+		Document: document,
+	}
+}
+
+func TestDocumentPolicyBuilderCondition(t *testing.T) {
+	request := &authorizer.AuthorizerRequest{
+		Token: &authorizer.VerifiedToken{
+			ID:     &authorizer.IDTokenClaims{},
+			Claims: map[string]interface{}{"custom:tenant": "acme"},
+		},
+		MethodArn:      "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/tenants/acme",
+		PathParameters: map[string]string{"tenantId": "acme"},
+	}
+
+	policy, err := createTestConditionPolicyBuilder(t).BuildPolicyForRequest(request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []events.IAMPolicyStatement{
+		allowStatement("arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/tenants/acme"),
+		{
+			Action:   []string{"execute-api:Invoke"},
+			Effect:   "Deny",
+			Resource: []string{"arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/*/admin/*"},
+		},
+	}, policy.Statement)
+	assert.True(t, statementsAllow(policy.Statement, "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/tenants/acme"))
+	assert.False(t, statementsAllow(policy.Statement, "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/tenants/other"))
+
+	request.PathParameters["tenantId"] = "other"
+	_, err = createTestConditionPolicyBuilder(t).BuildPolicyForRequest(request)
+
+	assert.True(t, errors.Is(err, authorizer.ErrAccessDenied))
+}
+
+func TestDocumentPolicyBuilderConditionRequestOutsideRoutes(t *testing.T) {
+	request := &authorizer.AuthorizerRequest{
+		Token: &authorizer.VerifiedToken{
+			ID:     &authorizer.IDTokenClaims{},
+			Claims: map[string]interface{}{"custom:tenant": "acme"},
+		},
+		MethodArn:      "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/items/acme",
+		PathParameters: map[string]string{"tenantId": "acme"},
+	}
+
+	_, err := createTestConditionPolicyBuilder(t).BuildPolicyForRequest(request)
+
+	assert.True(t, errors.Is(err, authorizer.ErrAccessDenied))
+}
+
+func TestDocumentPolicyBuilderConditionUsingRequestForToken(t *testing.T) {
+	token := &authorizer.VerifiedToken{
+		ID:     &authorizer.IDTokenClaims{},
+		Claims: map[string]interface{}{"custom:tenant": "acme"},
+	}
+
+	_, err := createTestConditionPolicyBuilder(t).BuildPolicyForToken(token)
+
+	assert.True(t, errors.Is(err, authorizer.ErrAccessDenied))
+}
+
+// statementsAllow tells whether statements allow the method ARN and do not deny it.
+func statementsAllow(statements []events.IAMPolicyStatement, methodARN string) bool {
+	arn, err := authorizer.ParseMethodARN(methodARN)
+	if err != nil {
+		return false
+	}
+
+	allowed := false
+	for _, statement := range statements {
+		for _, resource := range statement.Resource {
+			if !arn.Matches(resource) {
+				continue
+			}
+			if statement.Effect == "Deny" {
+				return false
+			}
+			allowed = true
+		}
+	}
+
+	return allowed
+}
+
+func TestDocumentPolicyBuilderConditionDenyEffect(t *testing.T) {
+	token := &authorizer.VerifiedToken{
+		ID:     &authorizer.IDTokenClaims{Groups: []string{"admins"}},
+		Claims: map[string]interface{}{"email_verified": true},
+	}
+
+	policy, err := createTestConditionPolicyBuilder(t).BuildPolicyForToken(token)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []events.IAMPolicyStatement{
+		allowStatement("arn:aws:execute-api:eu-west-1:*:api-id/prod/*/tenants/*"),
+		allowStatement("arn:aws:execute-api:eu-west-1:*:api-id/prod/*/admin/*"),
+	}, policy.Statement)
+}
+
+func TestDocumentPolicyBuilderConditionDenyEffectFailsClosed(t *testing.T) {
+	document, err := ParsePolicyDocument([]byte(`
+version: 1
+rules:
+  - groups: [admins]
+    routes:
+      - {method: "*", resource: /admin/*}
+  - condition: '!claims.email_verified'
+    effect: deny
+    routes:
+      - {method: "*", resource: /admin/*}
+`))
+	assert.Nil(t, err)
+	builder := &DocumentPolicyBuilder{
+		Context:  &authorizer.Context{Region: "eu-west-1", ApplicationID: "api-id", Stage: "prod"},
+		Document: document,
+	}
+	token := &authorizer.VerifiedToken{
+		ID:     &authorizer.IDTokenClaims{Groups: []string{"admins"}},
+		Claims: map[string]interface{}{"email_verified": "false"},
+	}
+
+	policy, err := builder.BuildPolicyForToken(token)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []events.IAMPolicyStatement{
+		allowStatement("arn:aws:execute-api:eu-west-1:*:api-id/prod/*/admin/*"),
+		{
+			Action:   []string{"execute-api:Invoke"},
+			Effect:   "Deny",
+			Resource: []string{"arn:aws:execute-api:eu-west-1:*:api-id/prod/*/admin/*"},
+		},
+	}, policy.Statement)
+}
+
+func TestDocumentPolicyBuilderConditionMissingClaims(t *testing.T) {
+	token := &authorizer.VerifiedToken{
+		ID:     &authorizer.IDTokenClaims{},
+		Claims: map[string]interface{}{},
+	}
+
+	_, err := createTestConditionPolicyBuilder(t).BuildPolicyForToken(token)
+
+	assert.True(t, errors.Is(err, authorizer.ErrAccessDenied))
+}
+
+func TestDocumentPolicyBuilderUncompiledCondition(t *testing.T) {
+	builder := &DocumentPolicyBuilder{
+		Context: &authorizer.Context{Region: "eu-west-1", ApplicationID: "api-id", Stage: "prod"},
+		Document: &PolicyDocument{
+			Version: 1,
+			Rules: []PolicyRule{
+				{Condition: `request.stage == "prod"`, Routes: []Route{{Method: "GET", Resource: "/items"}}},
+			},
+		},
+	}
+
+	policy, err := builder.BuildPolicyForToken(&authorizer.VerifiedToken{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, allowStatement("arn:aws:execute-api:eu-west-1:*:api-id/prod/GET/items"), policy.Statement[0])
+}
+
+func TestParsePolicyDocumentInvalidCondition(t *testing.T) {
+	for _, data := range []string{
+		"version: 1\nrules: [{condition: 'claims.a ==', routes: [{method: GET, resource: /}]}]",
+		"version: 1\nrules: [{condition: 'true', effect: block, routes: [{method: GET, resource: /}]}]",
+	} {
+		_, err := ParsePolicyDocument([]byte(data))
+
+		assert.True(t, errors.Is(err, ErrInvalidPolicyDocument), data)
+	}
+}
+
+func TestDocumentPolicyBuilderConditionUsingRequestWithoutMethodARN(t *testing.T) {
+	document, err := ParsePolicyDocument([]byte(`
+version: 1
+rules:
+  - condition: 'request.method != "DELETE"'
+    routes:
+      - {method: "*", resource: /*}
+`))
+	assert.Nil(t, err)
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	context := &authorizer.Context{
+		Region:            "eu-west-1",
+		ApplicationID:     "api-id",
+		Stage:             "prod",
+		AllowedUserPoolID: "eu-west-1_pool",
+		CognitoClients:    []string{"client"},
+		Keys: authorizer.PublicKeys{"test-key": &authorizer.PublicKey{
+			JWKey: authorizer.JWKey{Algorithm: "RS256", KeyID: "test-key", KeyType: "RSA", Use: "sig"},
+			Key:   &privateKey.PublicKey,
+		}},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":       "https://cognito-idp.eu-west-1.amazonaws.com/eu-west-1_pool",
+		"client_id": "client",
+		"token_use": "access",
+		"sub":       "test-subject",
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = "test-key"
+	encodedToken, err := token.SignedString(privateKey)
+	assert.Nil(t, err)
+	responseBuilder := authorizer.ResponseBuilder{
+		Context:       context,
+		PolicyBuilder: &DocumentPolicyBuilder{Context: context, Document: document},
+	}
+
+	response, err := responseBuilder.BuildResponse(encodedToken)
+
+	assert.Nil(t, err)
+	assert.False(t, statementsAllow(response.PolicyDocument.Statement, "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/DELETE/items"))
+	assert.False(t, statementsAllow(response.PolicyDocument.Statement, "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/items"))
+
+	response, err = responseBuilder.BuildTokenResponse(events.APIGatewayCustomAuthorizerRequest{
+		AuthorizationToken: encodedToken,
+		MethodArn:          "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/items",
+	})
+
+	assert.Nil(t, err)
+	assert.True(t, statementsAllow(response.PolicyDocument.Statement, "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/items"))
+	assert.False(t, statementsAllow(response.PolicyDocument.Statement, "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/DELETE/items"))
+}
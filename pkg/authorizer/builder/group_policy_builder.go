@@ -0,0 +1,89 @@
+package builder
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nordcloud/cognito-authorizer/pkg/authorizer"
+	log "github.com/sirupsen/logrus"
+)
+
+// GroupPolicyBuilder implements the PolicyBuilder interface.
+// It allows routes mapped to the Cognito groups (cognito:groups claim) the user belongs to.
+// Users without any mapped group are denied with authorizer.AccessDeniedError.
+type GroupPolicyBuilder struct {
+	Context *authorizer.Context
+	Groups  map[string][]Route
+}
+
+// BuildPolicy builds proper apigw policy based on groups from claims.
+func (p *GroupPolicyBuilder) BuildPolicy(encodedToken string) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	token, err := authorizer.NewVerifier(p.Context).Verify(encodedToken)
+	if err != nil {
+		log.Error("Failed to verify token.")
+		return events.APIGatewayCustomAuthorizerPolicy{}, err
+	}
+
+	return p.BuildPolicyForToken(token)
+}
+
+// BuildPolicyForToken builds proper apigw policy based on groups from verified token claims.
+func (p *GroupPolicyBuilder) BuildPolicyForToken(token *authorizer.VerifiedToken) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	return p.buildPolicy(token, p.contextAPIARN())
+}
+
+// BuildPolicyForRequest builds proper apigw policy based on groups, scoped to the account of the request method ARN.
+func (p *GroupPolicyBuilder) BuildPolicyForRequest(request *authorizer.AuthorizerRequest) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	api, err := requestAPIARN(request, p.contextAPIARN())
+	if err != nil {
+		return events.APIGatewayCustomAuthorizerPolicy{}, err
+	}
+
+	return p.buildPolicy(request.Token, api)
+}
+
+func (p *GroupPolicyBuilder) contextAPIARN() authorizer.MethodARN {
+	return authorizer.NewAPIARN(p.Context.Region, p.Context.ApplicationID, p.Context.Stage)
+}
+
+func (p *GroupPolicyBuilder) buildPolicy(token *authorizer.VerifiedToken, api authorizer.MethodARN) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	groups := tokenGroups(token)
+
+	var resources []string
+	allowed := map[string]bool{}
+	for _, group := range groups {
+		for _, route := range p.Groups[group] {
+			resource := routeResource(api, route)
+			if allowed[resource] {
+				continue
+			}
+			allowed[resource] = true
+			resources = append(resources, resource)
+		}
+	}
+
+	if len(resources) == 0 {
+		log.WithField("groups", groups).Error("No routes allowed for user groups.")
+		return events.APIGatewayCustomAuthorizerPolicy{}, &authorizer.AccessDeniedError{Reason: "no routes allowed for user groups"}
+	}
+
+	log.WithField("groups", groups).Info("Generating access for the groups")
+	return events.APIGatewayCustomAuthorizerPolicy{
+		Version: "2012-10-17",
+		Statement: []events.IAMPolicyStatement{
+			{
+				Action:   []string{"execute-api:Invoke"},
+				Effect:   string(allow),
+				Resource: resources,
+			},
+		},
+	}, nil
+}
+
+func tokenGroups(token *authorizer.VerifiedToken) []string {
+	if token.Access != nil {
+		return token.Access.Groups
+	}
+	if token.ID != nil {
+		return token.ID.Groups
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package builder
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nordcloud/cognito-authorizer/pkg/authorizer"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestGroupPolicyBuilder() *GroupPolicyBuilder {
+	return &GroupPolicyBuilder{
+		Context: &authorizer.Context{
+			Region:        "eu-west-1",
+			ApplicationID: "api-id",
+			Stage:         "prod",
+		},
+		Groups: map[string][]Route{
+			"admins":  {{Method: "*", Resource: "/*"}},
+			"readers": {{Method: "get", Resource: "/items/*"}, {Method: "GET", Resource: "/users"}},
+		},
+	}
+}
+
+func TestGroupPolicyBuilderBuildPolicyForToken(t *testing.T) {
+	token := &authorizer.VerifiedToken{
+		Access: &authorizer.AccessTokenClaims{Groups: []string{"readers", "unknown"}},
+	}
+
+	policy, err := createTestGroupPolicyBuilder().BuildPolicyForToken(token)
+
+	assert.Nil(t, err)
+	assert.Equal(t, events.APIGatewayCustomAuthorizerPolicy{
+		Version: "2012-10-17",
+		Statement: []events.IAMPolicyStatement{
+			{
+				Action: []string{"execute-api:Invoke"},
+				Effect: "Allow",
+				Resource: []string{
+					"arn:aws:execute-api:eu-west-1:*:api-id/prod/GET/items/*",
+					"arn:aws:execute-api:eu-west-1:*:api-id/prod/GET/users",
+				},
+			},
+		},
+	}, policy)
+}
+
+func TestGroupPolicyBuilderBuildPolicyForIDToken(t *testing.T) {
+	token := &authorizer.VerifiedToken{
+		ID: &authorizer.IDTokenClaims{Groups: []string{"admins"}},
+	}
+
+	policy, err := createTestGroupPolicyBuilder().BuildPolicyForToken(token)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"arn:aws:execute-api:eu-west-1:*:api-id/prod/*/*"}, policy.Statement[0].Resource)
+}
+
+func TestGroupPolicyBuilderSharedRoutes(t *testing.T) {
+	builder := createTestGroupPolicyBuilder()
+	builder.Groups["editors"] = []Route{{Method: "GET", Resource: "/items/*"}, {Method: "PUT", Resource: "/items/*"}}
+	token := &authorizer.VerifiedToken{
+		Access: &authorizer.AccessTokenClaims{Groups: []string{"readers", "editors"}},
+	}
+
+	policy, err := builder.BuildPolicyForToken(token)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"arn:aws:execute-api:eu-west-1:*:api-id/prod/GET/items/*",
+		"arn:aws:execute-api:eu-west-1:*:api-id/prod/GET/users",
+		"arn:aws:execute-api:eu-west-1:*:api-id/prod/PUT/items/*",
+	}, policy.Statement[0].Resource)
+}
+
+func TestGroupPolicyBuilderNoGroups(t *testing.T) {
+	token := &authorizer.VerifiedToken{
+		Access: &authorizer.AccessTokenClaims{Groups: []string{"unknown"}},
+	}
+
+	_, err := createTestGroupPolicyBuilder().BuildPolicyForToken(token)
+
+	assert.True(t, errors.Is(err, authorizer.ErrAccessDenied))
+}
+
+func TestGroupPolicyBuilderBuildPolicyForRequest(t *testing.T) {
+	request := &authorizer.AuthorizerRequest{
+		Token:     &authorizer.VerifiedToken{Access: &authorizer.AccessTokenClaims{Groups: []string{"readers"}}},
+		MethodArn: "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/items/1",
+	}
+
+	policy, err := createTestGroupPolicyBuilder().BuildPolicyForRequest(request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/items/*",
+		"arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/users",
+	}, policy.Statement[0].Resource)
+}
+
+func TestGroupPolicyBuilderBuildPolicyForRequestOfOtherAPI(t *testing.T) {
+	request := &authorizer.AuthorizerRequest{
+		Token:     &authorizer.VerifiedToken{Access: &authorizer.AccessTokenClaims{Groups: []string{"readers"}}},
+		MethodArn: "arn:aws:execute-api:eu-west-1:123456789012:other-api/dev/GET/items/1",
+	}
+
+	policy, err := createTestGroupPolicyBuilder().BuildPolicyForRequest(request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/items/*",
+		"arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/users",
+	}, policy.Statement[0].Resource)
+}
+
+func TestGroupPolicyBuilderInvalidMethodARN(t *testing.T) {
+	request := &authorizer.AuthorizerRequest{
+		Token:     &authorizer.VerifiedToken{Access: &authorizer.AccessTokenClaims{Groups: []string{"readers"}}},
+		MethodArn: "invalid",
+	}
+
+	_, err := createTestGroupPolicyBuilder().BuildPolicyForRequest(request)
+
+	assert.True(t, errors.Is(err, authorizer.ErrInvalidMethodARN))
+}
@@ -0,0 +1,231 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyDocumentVersion is the only supported version of policy documents.
+const PolicyDocumentVersion = 1
+
+// ErrInvalidPolicyDocument is matched by errors returned when policy document can not be read or is not valid.
+var ErrInvalidPolicyDocument = errors.New("policy document is invalid")
+
+var routeMethods = map[string]bool{
+	"*":       true,
+	"GET":     true,
+	"HEAD":    true,
+	"POST":    true,
+	"PUT":     true,
+	"PATCH":   true,
+	"DELETE":  true,
+	"OPTIONS": true,
+}
+
+// PolicyDocument describes authorization rules, it is read from JSON or YAML:
+//
+//	version: 1
+//	denyUnmatched: true
+//	rules:
+//	  - scopes: [items.read]
+//	    routes:
+//	      - {method: GET, resource: /items/*}
+//	  - groups: [admins]
+//	    stages: [dev]
+//	    routes:
+//	      - {method: "*", resource: /*}
+//	  - condition: 'claims["custom:tenant"] == path.tenantId'
+//	    routes:
+//	      - {method: GET, resource: /tenants/*}
+type PolicyDocument struct {
+	Version       int          `json:"version" yaml:"version"`
+	DenyUnmatched bool         `json:"denyUnmatched" yaml:"denyUnmatched"`
+	Rules         []PolicyRule `json:"rules" yaml:"rules"`
+}
+
+// PolicyRule grants routes to tokens matching all of its conditions: one of Scopes (with or without
+// the resource server prefix), one of Groups (cognito:groups claim), all Claims values and
+// the Condition expression (see Condition). Claims values are strings, numbers or booleans,
+// they are compared with the string form of token claims, so `true` matches both true and "true".
+// At least one condition is required. Rule applies only to Stages when they are given.
+// Routes of matching rules with `deny` Effect are denied explicitly.
+type PolicyRule struct {
+	Scopes    []string               `json:"scopes" yaml:"scopes"`
+	Groups    []string               `json:"groups" yaml:"groups"`
+	Claims    map[string]interface{} `json:"claims" yaml:"claims"`
+	Condition string                 `json:"condition" yaml:"condition"`
+	Effect    string                 `json:"effect" yaml:"effect"`
+	Stages    []string               `json:"stages" yaml:"stages"`
+	Routes    []Route                `json:"routes" yaml:"routes"`
+
+	condition *Condition
+}
+
+// PolicyDocumentError describes invalid policy document, it matches ErrInvalidPolicyDocument.
+// Rule is the index of the invalid rule, -1 when the whole document is invalid.
+type PolicyDocumentError struct {
+	Rule   int
+	Reason string
+}
+
+func (e *PolicyDocumentError) Error() string {
+	if e.Rule < 0 {
+		return fmt.Sprintf("%s: %s", ErrInvalidPolicyDocument, e.Reason)
+	}
+	return fmt.Sprintf("%s: rule %d: %s", ErrInvalidPolicyDocument, e.Rule, e.Reason)
+}
+
+// Is reports whether target is ErrInvalidPolicyDocument.
+func (e *PolicyDocumentError) Is(target error) bool {
+	return target == ErrInvalidPolicyDocument
+}
+
+// ParsePolicyDocument decodes and validates JSON or YAML policy document. Unknown fields are rejected.
+func ParsePolicyDocument(data []byte) (*PolicyDocument, error) {
+	document := &PolicyDocument{}
+
+	var err error
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(document)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(document)
+	}
+
+	if err != nil {
+		return nil, &PolicyDocumentError{Rule: -1, Reason: err.Error()}
+	}
+
+	err = document.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// ReadPolicyDocument reads policy document from r.
+func ReadPolicyDocument(r io.Reader) (*PolicyDocument, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePolicyDocument(data)
+}
+
+// LoadPolicyDocument reads policy document from a local file.
+func LoadPolicyDocument(path string) (*PolicyDocument, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePolicyDocument(data)
+}
+
+// LoadPolicyDocumentFS reads policy document from a file system, e.g. a file embedded with embed.FS.
+func LoadPolicyDocumentFS(fsys fs.FS, name string) (*PolicyDocument, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePolicyDocument(data)
+}
+
+// Validate checks the document version and that every rule has conditions, valid effect and routes.
+// Rule conditions are compiled, so they are not parsed for every token.
+func (d *PolicyDocument) Validate() error {
+	if d.Version != PolicyDocumentVersion {
+		return &PolicyDocumentError{Rule: -1, Reason: fmt.Sprintf("unsupported version %d", d.Version)}
+	}
+
+	if len(d.Rules) == 0 {
+		return &PolicyDocumentError{Rule: -1, Reason: "no rules"}
+	}
+
+	for i := range d.Rules {
+		err := d.Rules[i].validate(i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validate checks the rule and compiles its condition.
+func (r *PolicyRule) validate(index int) error {
+	if len(r.Scopes) == 0 && len(r.Groups) == 0 && len(r.Claims) == 0 && r.Condition == "" {
+		return &PolicyDocumentError{Rule: index, Reason: "no scopes, groups, claims or condition"}
+	}
+
+	if r.Effect != "" && !strings.EqualFold(r.Effect, string(allow)) && !strings.EqualFold(r.Effect, string(deny)) {
+		return &PolicyDocumentError{Rule: index, Reason: fmt.Sprintf("unsupported effect %q", r.Effect)}
+	}
+
+	for name, value := range r.Claims {
+		if _, ok := claimString(value); !ok {
+			return &PolicyDocumentError{Rule: index, Reason: fmt.Sprintf("claim %q value is not a string, number or boolean", name)}
+		}
+	}
+
+	if r.Condition != "" {
+		condition, err := ParseCondition(r.Condition)
+		if err != nil {
+			return &PolicyDocumentError{Rule: index, Reason: err.Error()}
+		}
+		r.condition = condition
+	}
+
+	if len(r.Routes) == 0 {
+		return &PolicyDocumentError{Rule: index, Reason: "no routes"}
+	}
+
+	for _, route := range r.Routes {
+		if !routeMethods[strings.ToUpper(route.Method)] {
+			return &PolicyDocumentError{Rule: index, Reason: fmt.Sprintf("unsupported method %q", route.Method)}
+		}
+
+		if route.Resource != "*" && !strings.HasPrefix(route.Resource, "/") {
+			return &PolicyDocumentError{Rule: index, Reason: fmt.Sprintf("resource %q does not start with /", route.Resource)}
+		}
+	}
+
+	return nil
+}
+
+// claimString returns the string form of a scalar claim value, numbers are formatted without exponent.
+func claimString(value interface{}) (string, bool) {
+	switch value := value.(type) {
+	case string:
+		return value, true
+	case bool:
+		return strconv.FormatBool(value), true
+	case int:
+		return strconv.Itoa(value), true
+	case int64:
+		return strconv.FormatInt(value, 10), true
+	case uint64:
+		return strconv.FormatUint(value, 10), true
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	case json.Number:
+		return value.String(), true
+	}
+
+	return "", false
+}
@@ -0,0 +1,141 @@
+package builder
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPolicyDocumentYAML = `
+version: 1
+denyUnmatched: true
+rules:
+  - scopes: [items.read]
+    routes:
+      - {method: GET, resource: /items/*}
+  - groups: [admins]
+    stages: [dev]
+    routes:
+      - {method: "*", resource: "*"}
+  - claims:
+      custom:tenant_id: acme
+    routes:
+      - {method: POST, resource: /items}
+`
+
+const testPolicyDocumentJSON = `{
+	"version": 1,
+	"denyUnmatched": true,
+	"rules": [
+		{"scopes": ["items.read"], "routes": [{"method": "GET", "resource": "/items/*"}]},
+		{"groups": ["admins"], "stages": ["dev"], "routes": [{"method": "*", "resource": "*"}]},
+		{"claims": {"custom:tenant_id": "acme"}, "routes": [{"method": "POST", "resource": "/items"}]}
+	]
+}`
+
+func createTestPolicyDocument() *PolicyDocument {
+	return &PolicyDocument{
+		Version:       1,
+		DenyUnmatched: true,
+		Rules: []PolicyRule{
+			{Scopes: []string{"items.read"}, Routes: []Route{{Method: "GET", Resource: "/items/*"}}},
+			{Groups: []string{"admins"}, Stages: []string{"dev"}, Routes: []Route{{Method: "*", Resource: "*"}}},
+			{Claims: map[string]interface{}{"custom:tenant_id": "acme"}, Routes: []Route{{Method: "POST", Resource: "/items"}}},
+		},
+	}
+}
+
+func TestParsePolicyDocument(t *testing.T) {
+	for name, data := range map[string]string{"yaml": testPolicyDocumentYAML, "json": testPolicyDocumentJSON} {
+		t.Run(name, func(t *testing.T) {
+			document, err := ParsePolicyDocument([]byte(data))
+
+			assert.Nil(t, err)
+			assert.Equal(t, createTestPolicyDocument(), document)
+		})
+	}
+}
+
+func TestParsePolicyDocumentInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "malformed", data: "version: [1"},
+		{name: "unknownFieldYAML", data: "version: 1\nrule: []"},
+		{name: "unknownFieldJSON", data: `{"version": 1, "rule": []}`},
+		{name: "version", data: "version: 2\nrules: [{scopes: [a], routes: [{method: GET, resource: /}]}]"},
+		{name: "noRules", data: "version: 1"},
+		{name: "noConditions", data: "version: 1\nrules: [{routes: [{method: GET, resource: /}]}]"},
+		{name: "noRoutes", data: "version: 1\nrules: [{scopes: [a]}]"},
+		{name: "method", data: "version: 1\nrules: [{scopes: [a], routes: [{method: FETCH, resource: /}]}]"},
+		{name: "resource", data: "version: 1\nrules: [{scopes: [a], routes: [{method: GET, resource: items}]}]"},
+		{name: "claimValue", data: `{"version": 1, "rules": [{"claims": {"email": {"a": 1}}, "routes": [{"method": "GET", "resource": "/"}]}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePolicyDocument([]byte(tt.data))
+
+			assert.True(t, errors.Is(err, ErrInvalidPolicyDocument), err)
+		})
+	}
+}
+
+func TestParsePolicyDocumentScalarClaims(t *testing.T) {
+	documents := map[string]string{
+		"yaml": "version: 1\nrules: [{claims: {email_verified: true, tier: 2}, routes: [{method: GET, resource: /}]}]",
+		"json": `{"version": 1, "rules": [{"claims": {"email_verified": true, "tier": 2}, "routes": [{"method": "GET", "resource": "/"}]}]}`,
+	}
+
+	for name, data := range documents {
+		t.Run(name, func(t *testing.T) {
+			document, err := ParsePolicyDocument([]byte(data))
+
+			assert.Nil(t, err)
+			claims := document.Rules[0].Claims
+			assert.True(t, claimHasValue(true, claims["email_verified"]))
+			assert.True(t, claimHasValue("true", claims["email_verified"]))
+			assert.False(t, claimHasValue(false, claims["email_verified"]))
+			assert.True(t, claimHasValue(float64(2), claims["tier"]))
+			assert.True(t, claimHasValue("2", claims["tier"]))
+		})
+	}
+}
+
+func TestReadPolicyDocument(t *testing.T) {
+	document, err := ReadPolicyDocument(strings.NewReader(testPolicyDocumentYAML))
+
+	assert.Nil(t, err)
+	assert.Equal(t, createTestPolicyDocument(), document)
+}
+
+func TestLoadPolicyDocument(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "policy")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "policy.yaml")
+	ioutil.WriteFile(path, []byte(testPolicyDocumentYAML), 0600)
+
+	document, err := LoadPolicyDocument(path)
+
+	assert.Nil(t, err)
+	assert.Equal(t, createTestPolicyDocument(), document)
+
+	_, err = LoadPolicyDocument(filepath.Join(dir, "missing.yaml"))
+	assert.NotNil(t, err)
+}
+
+func TestLoadPolicyDocumentFS(t *testing.T) {
+	fsys := fstest.MapFS{"policy.json": {Data: []byte(testPolicyDocumentJSON)}}
+
+	document, err := LoadPolicyDocumentFS(fsys, "policy.json")
+
+	assert.Nil(t, err)
+	assert.Equal(t, createTestPolicyDocument(), document)
+}
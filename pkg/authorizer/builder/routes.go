@@ -0,0 +1,107 @@
+package builder
+
+import (
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nordcloud/cognito-authorizer/pkg/authorizer"
+)
+
+// Route is an API method and resource path, e.g. `GET` and `/items/*`. `*` matches any method or path.
+type Route struct {
+	Method   string `json:"method" yaml:"method"`
+	Resource string `json:"resource" yaml:"resource"`
+}
+
+// routeResource builds ARN of the route in the API stage.
+func routeResource(api authorizer.MethodARN, route Route) string {
+	return api.WithRoute(strings.ToUpper(route.Method), route.Resource).String()
+}
+
+// routeStatements collects an Allow statement for every granted route, resources of routes not granted
+// and resources of routes denied explicitly.
+type routeStatements struct {
+	api        authorizer.MethodARN
+	allowed    map[string]bool
+	statements []events.IAMPolicyStatement
+	unmatched  []string
+	denied     []string
+}
+
+func newRouteStatements(api authorizer.MethodARN) *routeStatements {
+	return &routeStatements{api: api, allowed: map[string]bool{}}
+}
+
+func (s *routeStatements) add(routes []Route, granted bool) {
+	for _, route := range routes {
+		resource := routeResource(s.api, route)
+		if !granted {
+			s.unmatched = append(s.unmatched, resource)
+			continue
+		}
+
+		s.allowResource(resource)
+	}
+}
+
+func (s *routeStatements) allowResource(resource string) {
+	if s.allowed[resource] {
+		return
+	}
+	s.allowed[resource] = true
+
+	s.statements = append(s.statements, events.IAMPolicyStatement{
+		Action:   []string{"execute-api:Invoke"},
+		Effect:   string(allow),
+		Resource: []string{resource},
+	})
+}
+
+// allowRequest allows only the request method ARN when it matches one of routes, so the policy
+// does not grant other paths of the routes the rule was not evaluated for.
+func (s *routeStatements) allowRequest(routes []Route, request authorizer.MethodARN) {
+	for _, route := range routes {
+		if request.Matches(routeResource(s.api, route)) {
+			s.allowResource(request.String())
+			return
+		}
+	}
+}
+
+func (s *routeStatements) deny(routes []Route) {
+	for _, route := range routes {
+		s.denied = append(s.denied, routeResource(s.api, route))
+	}
+}
+
+// policy returns the policy with a Deny statement for routes denied explicitly and,
+// with denyUnmatched, for routes not granted by any rule.
+// It is false when no route was granted.
+func (s *routeStatements) policy(denyUnmatched bool) (events.APIGatewayCustomAuthorizerPolicy, bool) {
+	if len(s.statements) == 0 {
+		return events.APIGatewayCustomAuthorizerPolicy{}, false
+	}
+
+	statements := s.statements
+	denied := append([]string(nil), s.denied...)
+	if denyUnmatched {
+		for _, resource := range s.unmatched {
+			if !s.allowed[resource] {
+				denied = append(denied, resource)
+			}
+		}
+	}
+
+	if len(denied) > 0 {
+		statements = append(statements, events.IAMPolicyStatement{
+			Action:   []string{"execute-api:Invoke"},
+			Effect:   string(deny),
+			Resource: denied,
+		})
+	}
+
+	return events.APIGatewayCustomAuthorizerPolicy{
+		Version:   "2012-10-17",
+		Statement: statements,
+	}, true
+}
@@ -0,0 +1,90 @@
+package builder
+
+import (
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nordcloud/cognito-authorizer/pkg/authorizer"
+	log "github.com/sirupsen/logrus"
+)
+
+// ScopeRule maps an OAuth scope to the routes it grants.
+// Scope is either full, e.g. `https://api.example.com/items.read`, or without the resource server prefix, e.g. `items.read`.
+type ScopeRule struct {
+	Scope  string
+	Routes []Route
+}
+
+// ScopePolicyBuilder implements the PolicyBuilder interface.
+// It emits an Allow statement for every route granted by scopes of the access token (scope claim).
+// With DenyUnmatched routes of other rules are denied explicitly. IAM explicit deny wins over allow,
+// so routes of different rules should not overlap. Tokens without any granted route are denied
+// with authorizer.AccessDeniedError.
+type ScopePolicyBuilder struct {
+	Context       *authorizer.Context
+	Rules         []ScopeRule
+	DenyUnmatched bool
+}
+
+// BuildPolicy builds proper apigw policy based on scope from claims.
+func (p *ScopePolicyBuilder) BuildPolicy(encodedToken string) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	token, err := authorizer.NewVerifier(p.Context).Verify(encodedToken)
+	if err != nil {
+		log.Error("Failed to verify token.")
+		return events.APIGatewayCustomAuthorizerPolicy{}, err
+	}
+
+	return p.BuildPolicyForToken(token)
+}
+
+// BuildPolicyForToken builds proper apigw policy based on scope from verified token claims.
+func (p *ScopePolicyBuilder) BuildPolicyForToken(token *authorizer.VerifiedToken) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	return p.buildPolicy(token, authorizer.NewAPIARN(p.Context.Region, p.Context.ApplicationID, p.Context.Stage))
+}
+
+// BuildPolicyForRequest builds proper apigw policy based on scope, scoped to the account of the request method ARN.
+func (p *ScopePolicyBuilder) BuildPolicyForRequest(request *authorizer.AuthorizerRequest) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	api, err := requestAPIARN(request, authorizer.NewAPIARN(p.Context.Region, p.Context.ApplicationID, p.Context.Stage))
+	if err != nil {
+		return events.APIGatewayCustomAuthorizerPolicy{}, err
+	}
+
+	return p.buildPolicy(request.Token, api)
+}
+
+func (p *ScopePolicyBuilder) buildPolicy(token *authorizer.VerifiedToken, api authorizer.MethodARN) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	var scopes []string
+	if token.Access != nil {
+		scopes = strings.Fields(token.Access.Scope)
+	}
+
+	statements := newRouteStatements(api)
+	for _, rule := range p.Rules {
+		statements.add(rule.Routes, hasScope(scopes, rule.Scope))
+	}
+
+	policy, ok := statements.policy(p.DenyUnmatched)
+	if !ok {
+		log.WithField("scopes", scopes).Error("No routes allowed for token scopes.")
+		return events.APIGatewayCustomAuthorizerPolicy{}, &authorizer.AccessDeniedError{Reason: "no routes allowed for token scopes"}
+	}
+
+	log.WithField("scopes", scopes).Info("Generating access for the scope")
+	return policy, nil
+}
+
+// hasScope tells whether one of token scopes matches the rule scope.
+// Rule scope without the resource server prefix matches the scope name of any resource server.
+func hasScope(scopes []string, ruleScope string) bool {
+	for _, scope := range scopes {
+		if scope == ruleScope {
+			return true
+		}
+
+		if !strings.Contains(ruleScope, "/") && scope[strings.LastIndex(scope, "/")+1:] == ruleScope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,102 @@
+package builder
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nordcloud/cognito-authorizer/pkg/authorizer"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestScopePolicyBuilder(denyUnmatched bool) *ScopePolicyBuilder {
+	return &ScopePolicyBuilder{
+		Context: &authorizer.Context{
+			Region:        "eu-west-1",
+			ApplicationID: "api-id",
+			Stage:         "prod",
+		},
+		Rules: []ScopeRule{
+			{Scope: "https://api.example.com/items.read", Routes: []Route{{Method: "GET", Resource: "/items"}, {Method: "GET", Resource: "/items/*"}}},
+			{Scope: "items.write", Routes: []Route{{Method: "POST", Resource: "/items"}, {Method: "GET", Resource: "/items"}}},
+			{Scope: "users.read", Routes: []Route{{Method: "GET", Resource: "/users/*"}}},
+		},
+		DenyUnmatched: denyUnmatched,
+	}
+}
+
+func createTestScopeToken(scope string) *authorizer.VerifiedToken {
+	return &authorizer.VerifiedToken{
+		Access: &authorizer.AccessTokenClaims{Scope: scope},
+	}
+}
+
+func allowStatement(resource string) events.IAMPolicyStatement {
+	return events.IAMPolicyStatement{
+		Action:   []string{"execute-api:Invoke"},
+		Effect:   "Allow",
+		Resource: []string{resource},
+	}
+}
+
+func TestScopePolicyBuilderBuildPolicyForToken(t *testing.T) {
+	token := createTestScopeToken("https://api.example.com/items.read https://api.example.com/items.write")
+
+	policy, err := createTestScopePolicyBuilder(false).BuildPolicyForToken(token)
+
+	assert.Nil(t, err)
+	assert.Equal(t, events.APIGatewayCustomAuthorizerPolicy{
+		Version: "2012-10-17",
+		Statement: []events.IAMPolicyStatement{
+			allowStatement("arn:aws:execute-api:eu-west-1:*:api-id/prod/GET/items"),
+			allowStatement("arn:aws:execute-api:eu-west-1:*:api-id/prod/GET/items/*"),
+			allowStatement("arn:aws:execute-api:eu-west-1:*:api-id/prod/POST/items"),
+		},
+	}, policy)
+}
+
+func TestScopePolicyBuilderDenyUnmatched(t *testing.T) {
+	token := createTestScopeToken("https://api.example.com/items.read")
+
+	policy, err := createTestScopePolicyBuilder(true).BuildPolicyForToken(token)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []events.IAMPolicyStatement{
+		allowStatement("arn:aws:execute-api:eu-west-1:*:api-id/prod/GET/items"),
+		allowStatement("arn:aws:execute-api:eu-west-1:*:api-id/prod/GET/items/*"),
+		{
+			Action: []string{"execute-api:Invoke"},
+			Effect: "Deny",
+			Resource: []string{
+				"arn:aws:execute-api:eu-west-1:*:api-id/prod/POST/items",
+				"arn:aws:execute-api:eu-west-1:*:api-id/prod/GET/users/*",
+			},
+		},
+	}, policy.Statement)
+}
+
+func TestScopePolicyBuilderBuildPolicyForRequest(t *testing.T) {
+	request := &authorizer.AuthorizerRequest{
+		Token:     createTestScopeToken("https://other.example.com/users.read"),
+		MethodArn: "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/users/1",
+	}
+
+	policy, err := createTestScopePolicyBuilder(false).BuildPolicyForRequest(request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []events.IAMPolicyStatement{
+		allowStatement("arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/users/*"),
+	}, policy.Statement)
+}
+
+func TestScopePolicyBuilderNoScopes(t *testing.T) {
+	for _, token := range []*authorizer.VerifiedToken{
+		createTestScopeToken("openid email"),
+		createTestScopeToken("https://api.example.com/users.read.all"),
+		{ID: &authorizer.IDTokenClaims{}},
+	} {
+		_, err := createTestScopePolicyBuilder(true).BuildPolicyForToken(token)
+
+		assert.True(t, errors.Is(err, authorizer.ErrAccessDenied))
+	}
+}
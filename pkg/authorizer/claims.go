@@ -0,0 +1,54 @@
+package authorizer
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const customAttributePrefix = "custom:"
+
+// Identity describes a federated identity provider the user signed in with (identities claim).
+type Identity struct {
+	UserID       string `json:"userId"`
+	ProviderName string `json:"providerName"`
+	ProviderType string `json:"providerType"`
+	Issuer       string `json:"issuer"`
+	Primary      string `json:"primary"`
+	DateCreated  string `json:"dateCreated"`
+}
+
+// CustomAttribute returns value of user pool custom attribute, name is given without `custom:` prefix.
+// Cognito stores custom attributes as strings, other values are formatted.
+func (c *IDTokenClaims) CustomAttribute(name string) (string, bool) {
+	value, ok := c.Claims[customAttributePrefix+name]
+	if !ok || value == nil {
+		return "", false
+	}
+
+	if text, ok := value.(string); ok {
+		return text, true
+	}
+
+	return fmt.Sprint(value), true
+}
+
+// Identities returns identities of a federated user, nil for users signed in to the user pool directly.
+func (c *IDTokenClaims) Identities() ([]Identity, error) {
+	value, ok := c.Claims["identities"]
+	if !ok || value == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var identities []Identity
+	err = json.Unmarshal(data, &identities)
+	if err != nil {
+		return nil, wrapError(ErrTokenMalformed, err)
+	}
+
+	return identities, nil
+}
@@ -0,0 +1,102 @@
+package authorizer
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testFederatedIDClaims = `{
+	"sub": "test-subject",
+	"token_use": "id",
+	"email": "test@example.com",
+	"family_name": "Doe",
+	"phone_number": "+48123456789",
+	"phone_number_verified": true,
+	"locale": "pl",
+	"custom:tenant_id": "tenant-1",
+	"custom:seats": 5,
+	"identities": [{
+		"userId": "1234567890",
+		"providerName": "Google",
+		"providerType": "Google",
+		"issuer": null,
+		"primary": "true",
+		"dateCreated": "1583247896433"
+	}]
+}`
+
+func createTestIDTokenClaims(payload string) IDTokenClaims {
+	claims := IDTokenClaims{}
+	json.Unmarshal([]byte(payload), &claims)
+	json.Unmarshal([]byte(payload), &claims.Claims)
+
+	return claims
+}
+
+func TestIDTokenClaimsEmbedded(t *testing.T) {
+	claims := struct {
+		IDTokenClaims
+		TenantID string `json:"custom:tenant_id"`
+	}{}
+
+	err := json.Unmarshal([]byte(testFederatedIDClaims), &claims)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "test-subject", claims.Subject)
+	assert.Equal(t, "Doe", claims.FamilyName)
+	assert.Equal(t, "+48123456789", claims.PhoneNumber)
+	assert.True(t, claims.PhoneNumberVerified)
+	assert.Equal(t, "tenant-1", claims.TenantID)
+}
+
+func TestIDTokenClaimsCustomAttribute(t *testing.T) {
+	claims := createTestIDTokenClaims(testFederatedIDClaims)
+
+	tenantID, ok := claims.CustomAttribute("tenant_id")
+	assert.True(t, ok)
+	assert.Equal(t, "tenant-1", tenantID)
+
+	seats, ok := claims.CustomAttribute("seats")
+	assert.True(t, ok)
+	assert.Equal(t, "5", seats)
+
+	_, ok = claims.CustomAttribute("missing")
+	assert.False(t, ok)
+}
+
+func TestIDTokenClaimsIdentities(t *testing.T) {
+	claims := createTestIDTokenClaims(testFederatedIDClaims)
+
+	identities, err := claims.Identities()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Identity{
+		{
+			UserID:       "1234567890",
+			ProviderName: "Google",
+			ProviderType: "Google",
+			Primary:      "true",
+			DateCreated:  "1583247896433",
+		},
+	}, identities)
+}
+
+func TestIDTokenClaimsNoIdentities(t *testing.T) {
+	claims := createTestIDTokenClaims(`{"sub": "test-subject"}`)
+
+	identities, err := claims.Identities()
+
+	assert.Nil(t, err)
+	assert.Nil(t, identities)
+}
+
+func TestIDTokenClaimsMalformedIdentities(t *testing.T) {
+	claims := createTestIDTokenClaims(`{"identities": "google"}`)
+
+	_, err := claims.Identities()
+
+	assert.True(t, errors.Is(err, ErrTokenMalformed))
+}
@@ -0,0 +1,215 @@
+package authorizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultMaxResponseSize is the size of serialized authorizer response (policy and context) API Gateway accepts.
+// Larger responses make API Gateway respond with 500.
+const DefaultMaxResponseSize = 8 * 1024
+
+// CompactPolicy merges statements with the same effect and actions into one statement, replaces resources
+// of a path listed for every HTTP method with one `*` method resource and drops resources matched by
+// a wildcard resource of the same statement, e.g. `.../prod/GET/items/1` when `.../prod/*/*` is present.
+// The compacted policy allows and denies the same requests. Distinct paths are never collapsed into
+// a path wildcard, since the policy does not tell which other paths exist; builders granting many paths
+// under a prefix should emit the wildcard resource themselves.
+func CompactPolicy(policy events.APIGatewayCustomAuthorizerPolicy) events.APIGatewayCustomAuthorizerPolicy {
+	var statements []events.IAMPolicyStatement
+	merged := map[string]int{}
+
+	for _, statement := range policy.Statement {
+		key := statementKey(statement)
+		i, ok := merged[key]
+		if !ok {
+			merged[key] = len(statements)
+			statements = append(statements, events.IAMPolicyStatement{
+				Action: statement.Action,
+				Effect: statement.Effect,
+			})
+			i = len(statements) - 1
+		}
+
+		statements[i].Resource = append(statements[i].Resource, statement.Resource...)
+	}
+
+	for i := range statements {
+		statements[i].Resource = compactResources(collapseMethods(statements[i].Resource))
+	}
+
+	policy.Statement = statements
+	return policy
+}
+
+// statementKey identifies statements that can be merged, actions are compared as a set.
+func statementKey(statement events.IAMPolicyStatement) string {
+	actions := append([]string(nil), statement.Action...)
+	sort.Strings(actions)
+
+	return statement.Effect + "\n" + strings.Join(actions, "\n")
+}
+
+// httpMethods are the HTTP methods of API Gateway method ARNs, a resource listed for all of them
+// matches the same requests as the resource with `*` method.
+var httpMethods = []string{"DELETE", "GET", "HEAD", "OPTIONS", "PATCH", "POST", "PUT"}
+
+// collapseMethods replaces method ARNs of a path listed for every method of httpMethods with
+// the ARN with `*` method, placed where the first of them was. Other resources are kept as they are.
+func collapseMethods(resources []string) []string {
+	methods := map[string]map[string]bool{}
+	for _, resource := range resources {
+		if key, method, ok := anyMethodResource(resource); ok {
+			if methods[key] == nil {
+				methods[key] = map[string]bool{}
+			}
+			methods[key][method] = true
+		}
+	}
+
+	var collapsed []string
+	added := map[string]bool{}
+
+	for _, resource := range resources {
+		key, _, ok := anyMethodResource(resource)
+		if !ok || !hasAllMethods(methods[key]) {
+			collapsed = append(collapsed, resource)
+			continue
+		}
+
+		if !added[key] {
+			added[key] = true
+			collapsed = append(collapsed, key)
+		}
+	}
+
+	return collapsed
+}
+
+// anyMethodResource returns method ARN resource with `*` in place of its method and the method.
+// It is false for other resources and for methods with wildcards.
+func anyMethodResource(resource string) (string, string, bool) {
+	parts := strings.SplitN(resource, "/", 4)
+	if len(parts) < 3 || !strings.HasPrefix(parts[0], "arn:") || strings.ContainsAny(parts[2], "*?") {
+		return "", "", false
+	}
+
+	method := parts[2]
+	parts[2] = "*"
+	return strings.Join(parts, "/"), method, true
+}
+
+func hasAllMethods(methods map[string]bool) bool {
+	for _, method := range httpMethods {
+		if !methods[method] {
+			return false
+		}
+	}
+	return true
+}
+
+// compactResources removes duplicates and resources matched by another wildcard resource, order is kept.
+func compactResources(resources []string) []string {
+	var compacted []string
+	seen := map[string]bool{}
+
+	for i, resource := range resources {
+		if seen[resource] {
+			continue
+		}
+		seen[resource] = true
+
+		covered := false
+		for j, other := range resources {
+			if i != j && other != resource && strings.Contains(other, "*") && wildcardMatch(other, resource) {
+				covered = true
+				break
+			}
+		}
+
+		if !covered {
+			compacted = append(compacted, resource)
+		}
+	}
+
+	return compacted
+}
+
+// wildcardMatch tells whether value matches pattern where `*` matches any sequence of characters.
+// Wildcards of value are compared as plain characters, so a match means pattern covers all values matched by value.
+func wildcardMatch(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	last := len(parts) - 1
+	if last == 0 {
+		return pattern == value
+	}
+
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+
+	for _, part := range parts[1:last] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+
+	return strings.HasSuffix(value, parts[last])
+}
+
+// fitResponse compacts the policy when serialized response is larger than the limit and fails with
+// ErrResponseTooLarge when it is still too large. policy points to the policy of response, nil for responses without policy.
+func (b ResponseBuilder) fitResponse(response interface{}, policy *events.APIGatewayCustomAuthorizerPolicy) error {
+	limit := b.MaxResponseSize
+	if limit == 0 {
+		limit = DefaultMaxResponseSize
+	}
+
+	size, err := responseSize(response)
+	if err != nil || size <= limit {
+		return err
+	}
+
+	if policy != nil {
+		*policy = CompactPolicy(*policy)
+
+		compacted, err := responseSize(response)
+		if err != nil {
+			return err
+		}
+
+		log.WithFields(log.Fields{
+			"size":      size,
+			"compacted": compacted,
+		}).Warn("Compacted authorizer response.")
+		size = compacted
+	}
+
+	if size > limit {
+		log.WithFields(log.Fields{
+			"size":  size,
+			"limit": limit,
+		}).Error("Authorizer response is too large.")
+		return &UnauthorizedError{Err: wrapError(ErrResponseTooLarge, fmt.Errorf("response has %d bytes, limit is %d", size, limit))}
+	}
+
+	return nil
+}
+
+func responseSize(response interface{}) (int, error) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		log.WithField("error", err).Error("Failed to marshal authorizer response.")
+		return 0, &UnauthorizedError{Err: wrapError(ErrResponseMarshal, err)}
+	}
+
+	return len(data), nil
+}
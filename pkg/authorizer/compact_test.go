@@ -0,0 +1,182 @@
+package authorizer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAPIARN = "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod"
+
+func testStatement(effect string, resources ...string) events.IAMPolicyStatement {
+	return events.IAMPolicyStatement{
+		Action:   []string{"execute-api:Invoke"},
+		Effect:   effect,
+		Resource: resources,
+	}
+}
+
+func TestCompactPolicy(t *testing.T) {
+	policy := events.APIGatewayCustomAuthorizerPolicy{
+		Version: "2012-10-17",
+		Statement: []events.IAMPolicyStatement{
+			testStatement("Allow", testAPIARN+"/GET/items/1"),
+			testStatement("Deny", testAPIARN+"/DELETE/items/1"),
+			testStatement("Allow", testAPIARN+"/GET/items/*"),
+			testStatement("Allow", testAPIARN+"/POST/items", testAPIARN+"/GET/items/1"),
+			testStatement("Deny", testAPIARN+"/*/admin/*", testAPIARN+"/DELETE/admin/users"),
+			{Action: []string{"execute-api:ManageConnections"}, Effect: "Allow", Resource: []string{testAPIARN + "/POST/@connections/*"}},
+		},
+	}
+
+	assert.Equal(t, events.APIGatewayCustomAuthorizerPolicy{
+		Version: "2012-10-17",
+		Statement: []events.IAMPolicyStatement{
+			testStatement("Allow", testAPIARN+"/GET/items/*", testAPIARN+"/POST/items"),
+			testStatement("Deny", testAPIARN+"/DELETE/items/1", testAPIARN+"/*/admin/*"),
+			{Action: []string{"execute-api:ManageConnections"}, Effect: "Allow", Resource: []string{testAPIARN + "/POST/@connections/*"}},
+		},
+	}, CompactPolicy(policy))
+}
+
+func TestCompactPolicyStageWildcard(t *testing.T) {
+	policy := events.APIGatewayCustomAuthorizerPolicy{
+		Statement: []events.IAMPolicyStatement{
+			testStatement("Allow", testAPIARN+"/GET/items"),
+			testStatement("Allow", testAPIARN+"/*/*"),
+			testStatement("Allow", testAPIARN+"/*/items/*"),
+		},
+	}
+
+	assert.Equal(t, []string{testAPIARN + "/*/*"}, CompactPolicy(policy).Statement[0].Resource)
+}
+
+func TestCompactPolicyCollapsesMethods(t *testing.T) {
+	var resources []string
+	for _, method := range []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"} {
+		resources = append(resources, testAPIARN+"/"+method+"/items", testAPIARN+"/"+method+"/")
+	}
+	resources = append(resources, testAPIARN+"/GET/users", testAPIARN+"/POST/users", testAPIARN+"/*/orders")
+
+	policy := events.APIGatewayCustomAuthorizerPolicy{
+		Statement: []events.IAMPolicyStatement{testStatement("Allow", resources...)},
+	}
+
+	assert.Equal(t, []string{
+		testAPIARN + "/*/items",
+		testAPIARN + "/*/",
+		testAPIARN + "/GET/users",
+		testAPIARN + "/POST/users",
+		testAPIARN + "/*/orders",
+	}, CompactPolicy(policy).Statement[0].Resource)
+}
+
+func TestWildcardMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{pattern: "*", value: "anything", want: true},
+		{pattern: "a/*/c", value: "a/b/c", want: true},
+		{pattern: "a/*/c", value: "a/b/d", want: false},
+		{pattern: "a/*", value: "a/*/c", want: true},
+		{pattern: "a/*/c/*", value: "a/*", want: false},
+		{pattern: "ab*ba", value: "aba", want: false},
+		{pattern: "a*b*c", value: "axxbyyc", want: true},
+		{pattern: "abc", value: "abc", want: true},
+		{pattern: "abc", value: "abd", want: false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, wildcardMatch(tt.pattern, tt.value), fmt.Sprintf("%s %s", tt.pattern, tt.value))
+	}
+}
+
+func createTestLargePolicy(routes int) events.APIGatewayCustomAuthorizerPolicy {
+	policy := events.APIGatewayCustomAuthorizerPolicy{Version: "2012-10-17"}
+	for i := 0; i < routes; i++ {
+		policy.Statement = append(policy.Statement, testStatement("Allow", fmt.Sprintf("%s/GET/items/%d", testAPIARN, i)))
+	}
+
+	return policy
+}
+
+func TestBuildResponseCompactsLargePolicy(t *testing.T) {
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+	policy := createTestLargePolicy(100)
+	policy.Statement = append(policy.Statement, testStatement("Allow", testAPIARN+"/GET/items/*"))
+
+	policyBuilderMock := new(policyBuilderMock)
+	policyBuilderMock.On("BuildPolicy", token).Return(policy, nil).Once()
+	contextBuilderMock := new(contextBuilderMock)
+	contextBuilderMock.On("BuildContext", token).Return(map[string]interface{}{}, nil).Once()
+
+	response, err := createTestResponseBuilder(policyBuilderMock, contextBuilderMock).BuildResponse(token)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []events.IAMPolicyStatement{testStatement("Allow", testAPIARN+"/GET/items/*")}, response.PolicyDocument.Statement)
+}
+
+func TestBuildResponseSmallPolicyNotCompacted(t *testing.T) {
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+	policy := createTestLargePolicy(3)
+
+	policyBuilderMock := new(policyBuilderMock)
+	policyBuilderMock.On("BuildPolicy", token).Return(policy, nil).Once()
+	contextBuilderMock := new(contextBuilderMock)
+	contextBuilderMock.On("BuildContext", token).Return(map[string]interface{}{}, nil).Once()
+
+	response, err := createTestResponseBuilder(policyBuilderMock, contextBuilderMock).BuildResponse(token)
+
+	assert.Nil(t, err)
+	assert.Equal(t, policy, response.PolicyDocument)
+}
+
+func TestBuildResponseTooLarge(t *testing.T) {
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+
+	policyBuilderMock := new(policyBuilderMock)
+	policyBuilderMock.On("BuildPolicy", token).Return(createTestLargePolicy(200), nil).Once()
+	contextBuilderMock := new(contextBuilderMock)
+	contextBuilderMock.On("BuildContext", token).Return(map[string]interface{}{}, nil).Once()
+
+	response, err := createTestResponseBuilder(policyBuilderMock, contextBuilderMock).BuildResponse(token)
+
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	assert.True(t, errors.Is(err, ErrResponseTooLarge))
+	assert.Equal(t, events.APIGatewayCustomAuthorizerResponse{}, response)
+}
+
+func TestBuildResponseMarshalError(t *testing.T) {
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+
+	policyBuilderMock := new(policyBuilderMock)
+	policyBuilderMock.On("BuildPolicy", token).Return(createTestLargePolicy(1), nil).Once()
+	contextBuilderMock := new(contextBuilderMock)
+	contextBuilderMock.On("BuildContext", token).Return(map[string]interface{}{"done": make(chan bool)}, nil).Once()
+
+	_, err := createTestResponseBuilder(policyBuilderMock, contextBuilderMock).BuildResponse(token)
+
+	assert.True(t, errors.Is(err, ErrResponseMarshal))
+	assert.False(t, errors.Is(err, ErrContextBuild))
+}
+
+func TestBuildV2SimpleResponseTooLarge(t *testing.T) {
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+
+	contextBuilderMock := new(contextBuilderMock)
+	contextBuilderMock.On("BuildContext", token).Return(map[string]interface{}{"claims": strings.Repeat("a", 1024)}, nil).Once()
+
+	responseBuilder := createTestResponseBuilder(nil, contextBuilderMock)
+	responseBuilder.MaxResponseSize = 1024
+
+	response, err := responseBuilder.BuildV2SimpleResponse(createTestV2Event(token))
+
+	assert.True(t, errors.Is(err, ErrResponseTooLarge))
+	assert.False(t, response.IsAuthorized)
+}
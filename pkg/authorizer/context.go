@@ -4,12 +4,84 @@ package authorizer
 	Package abstracts out work needed to retrieve AWS Cognito JW token claims.
 */
 
+import (
+	"fmt"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+const cognitoIssuerTemplate = "https://cognito-idp.%s.amazonaws.com/%s"
+
 // Context is a preset of data needed to build a response.
 type Context struct {
 	Region            string
 	ApplicationID     string
 	Stage             string
 	AllowedUserPoolID string
-	CognitoClients    []string
-	DecryptionKeys    []JWKey
+	// Issuer takes precedence over the issuer built from Region and AllowedUserPoolID.
+	Issuer         string
+	CognitoClients []string
+	DecryptionKeys []JWKey
+	// Keys takes precedence over DecryptionKeys when set, use KeySet to pick up rotated keys.
+	Keys KeyProvider
+	// Algorithms lists accepted signing algorithms, DefaultAlgorithms are used when empty.
+	Algorithms []string
+	// UserPools, when set, are the issuers of accepted tokens, fields describing a single pool
+	// (AllowedUserPoolID, Issuer, CognitoClients and keys) are not used.
+	UserPools []*UserPool
+	// Leeway is the allowed clock skew applied to exp, nbf and iat claims.
+	Leeway time.Duration
+	// MaxTokenAge, when set, rejects tokens issued (iat) longer ago.
+	MaxTokenAge time.Duration
+	// MaxAuthAge, when set, rejects tokens of users authenticated (auth_time) longer ago.
+	MaxAuthAge time.Duration
+}
+
+// KeyProvider returns provider of keys used to verify tokens.
+// DecryptionKeys are parsed on every call, set Keys to NewPublicKeys(keys) to parse them once.
+func (c *Context) KeyProvider() KeyProvider {
+	if c.Keys != nil {
+		return c.Keys
+	}
+
+	return NewPublicKeys(c.DecryptionKeys)
+}
+
+// ExpectedIssuer returns issuer of tokens created by the allowed user pool.
+func (c *Context) ExpectedIssuer() string {
+	if c.Issuer != "" {
+		return c.Issuer
+	}
+	return fmt.Sprintf(cognitoIssuerTemplate, c.Region, c.AllowedUserPoolID)
+}
+
+// ParseClaims verifies token with context keys and fills claims with its data.
+// Tokens not issued by the allowed user pool are rejected with ErrInvalidIssuer.
+func (c *Context) ParseClaims(encodedToken string, claims jwt.Claims) error {
+	var pool *UserPool
+	_, payload, err := parseWithClaims(encodedToken, claims, c.keyResolver(&pool), c.verifyOptions())
+	if err != nil {
+		return err
+	}
+
+	if idClaims, ok := claims.(*IDTokenClaims); ok {
+		idClaims.Claims = payload.values
+	}
+
+	return nil
+}
+
+func (c *Context) verifyOptions() verifyOptions {
+	algorithms := c.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = DefaultAlgorithms
+	}
+
+	return verifyOptions{
+		algorithms:  algorithms,
+		leeway:      c.Leeway,
+		maxTokenAge: c.MaxTokenAge,
+		maxAuthAge:  c.MaxAuthAge,
+	}
 }
@@ -0,0 +1,43 @@
+package authorizer
+
+import (
+	"errors"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// DenyPolicy builds a policy denying invocation of the resources, `*` when none are given.
+func DenyPolicy(resources ...string) events.APIGatewayCustomAuthorizerPolicy {
+	if len(resources) == 0 {
+		resources = []string{"*"}
+	}
+
+	return events.APIGatewayCustomAuthorizerPolicy{
+		Version: "2012-10-17",
+		Statement: []events.IAMPolicyStatement{
+			{
+				Action:   []string{"execute-api:Invoke"},
+				Effect:   "Deny",
+				Resource: resources,
+			},
+		},
+	}
+}
+
+// deniedPolicy returns a Deny policy when err is a deny outcome (matches ErrAccessDenied).
+func deniedPolicy(err error, request *AuthorizerRequest) (events.APIGatewayCustomAuthorizerPolicy, bool) {
+	if !errors.Is(err, ErrAccessDenied) {
+		return events.APIGatewayCustomAuthorizerPolicy{}, false
+	}
+
+	var denied *AccessDeniedError
+	if errors.As(err, &denied) && len(denied.Resource) > 0 {
+		return DenyPolicy(denied.Resource...), true
+	}
+
+	if request.MethodArn != "" {
+		return DenyPolicy(request.MethodArn), true
+	}
+
+	return DenyPolicy(), true
+}
@@ -0,0 +1,91 @@
+package authorizer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDenyPolicy(t *testing.T) {
+	assert.Equal(t, events.APIGatewayCustomAuthorizerPolicy{
+		Version: "2012-10-17",
+		Statement: []events.IAMPolicyStatement{
+			{
+				Action:   []string{"execute-api:Invoke"},
+				Effect:   "Deny",
+				Resource: []string{"*"},
+			},
+		},
+	}, DenyPolicy())
+	assert.Equal(t, []string{"arn-1", "arn-2"}, DenyPolicy("arn-1", "arn-2").Statement[0].Resource)
+}
+
+func TestAccessDeniedError(t *testing.T) {
+	err := &AccessDeniedError{Reason: "missing scope"}
+
+	assert.Equal(t, "access denied: missing scope", err.Error())
+	assert.True(t, errors.Is(err, ErrAccessDenied))
+	assert.Equal(t, "access denied", (&AccessDeniedError{}).Error())
+}
+
+func TestBuildResponseAccessDenied(t *testing.T) {
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+	methodArn := "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/DELETE/items/1"
+
+	tests := []struct {
+		name      string
+		err       error
+		methodArn string
+		want      []string
+	}{
+		{name: "sentinel", err: ErrAccessDenied, want: []string{"*"}},
+		{name: "wrappedSentinel", err: fmt.Errorf("missing scope: %w", ErrAccessDenied), want: []string{"*"}},
+		{name: "methodArn", err: &AccessDeniedError{Reason: "missing scope"}, methodArn: methodArn, want: []string{methodArn}},
+		{name: "resource", err: &AccessDeniedError{Resource: []string{"arn-1"}}, methodArn: methodArn, want: []string{"arn-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policyBuilderMock := new(requestPolicyBuilderMock)
+			policyBuilderMock.On("BuildPolicyForRequest", mock.Anything).Return(events.APIGatewayCustomAuthorizerPolicy{}, tt.err).Once()
+			contextBuilderMock := new(contextBuilderMock)
+			contextBuilderMock.On("BuildContext", token).Return(map[string]interface{}{"scope": "test-scope"}, nil).Once()
+
+			responseBuilder := createTestResponseBuilder(policyBuilderMock, contextBuilderMock)
+
+			response, err := responseBuilder.BuildRequestResponse(events.APIGatewayCustomAuthorizerRequestTypeRequest{
+				MethodArn: tt.methodArn,
+				Headers:   map[string]string{"Authorization": "Bearer " + token},
+			})
+
+			assert.Nil(t, err)
+			assert.Equal(t, events.APIGatewayCustomAuthorizerResponse{
+				PrincipalID:    "test-subject",
+				PolicyDocument: DenyPolicy(tt.want...),
+				Context:        map[string]interface{}{"scope": "test-scope"},
+			}, response)
+			policyBuilderMock.AssertExpectations(t)
+			contextBuilderMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBuildV2PolicyResponseAccessDenied(t *testing.T) {
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+	event := createTestV2Event(token)
+
+	policyBuilderMock := new(policyBuilderMock)
+	policyBuilderMock.On("BuildPolicy", token).Return(events.APIGatewayCustomAuthorizerPolicy{}, &AccessDeniedError{}).Once()
+	contextBuilderMock := new(contextBuilderMock)
+	contextBuilderMock.On("BuildContext", token).Return(map[string]interface{}{}, nil).Once()
+
+	response, err := createTestResponseBuilder(policyBuilderMock, contextBuilderMock).BuildV2PolicyResponse(event)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "test-subject", response.PrincipalID)
+	assert.Equal(t, DenyPolicy(event.RouteArn), response.PolicyDocument)
+}
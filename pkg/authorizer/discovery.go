@@ -0,0 +1,105 @@
+package authorizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	discoveryPath               = "/.well-known/openid-configuration"
+	cognitoDiscoveryURLTemplate = "https://cognito-idp.%s.amazonaws.com/%s" + discoveryPath
+)
+
+// ProviderMetadata holds OpenID provider configuration needed to verify its tokens.
+type ProviderMetadata struct {
+	Issuer                           string   `json:"issuer"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// CognitoDiscoveryURL returns URL of OpenID configuration of AWS Cognito user pool.
+func CognitoDiscoveryURL(region, userPoolID string) string {
+	return fmt.Sprintf(cognitoDiscoveryURLTemplate, region, userPoolID)
+}
+
+// DiscoverProvider fetches OpenID provider configuration.
+// discoveryURL is either the configuration document URL or the issuer URL,
+// in which case /.well-known/openid-configuration is appended. The issuer of the
+// configuration must be the URL it was discovered from, failures are returned as *DiscoveryError.
+func DiscoverProvider(ctx context.Context, client *http.Client, discoveryURL string) (*ProviderMetadata, error) {
+	issuer := strings.TrimSuffix(strings.TrimSuffix(discoveryURL, discoveryPath), "/")
+	discoveryURL = issuer + discoveryPath
+
+	metadata := &ProviderMetadata{}
+	err := requestJSON(ctx, client, discoveryURL, metadata)
+	if err != nil {
+		var requestErr *KeyRequestError
+		if errors.As(err, &requestErr) {
+			return nil, &DiscoveryError{URL: discoveryURL, StatusCode: requestErr.StatusCode, Err: requestErr.Err}
+		}
+		return nil, &DiscoveryError{URL: discoveryURL, Err: err}
+	}
+
+	if metadata.Issuer == "" || !isAbsoluteURL(metadata.JWKSURI) {
+		return nil, &DiscoveryError{URL: discoveryURL, Err: ErrInvalidProviderMetadata}
+	}
+
+	if strings.TrimSuffix(metadata.Issuer, "/") != issuer {
+		return nil, &DiscoveryError{URL: discoveryURL, Err: wrapError(ErrIssuerMismatch, fmt.Errorf("issuer is %s", metadata.Issuer))}
+	}
+
+	return metadata, nil
+}
+
+// Discover configures context issuer and keys from OpenID provider configuration.
+// Keys are fetched with KeySet, so the first fetch happens here and rotated keys are picked up later.
+// When Algorithms are not set, they are set to the supported ID token signing algorithms of the provider.
+// Issuer and keys of a context with UserPools are not used, so it is rejected with ErrUserPoolsConfigured.
+func (c *Context) Discover(ctx context.Context, client *http.Client, discoveryURL string) error {
+	if len(c.UserPools) > 0 {
+		return ErrUserPoolsConfigured
+	}
+
+	metadata, err := DiscoverProvider(ctx, client, discoveryURL)
+	if err != nil {
+		return err
+	}
+
+	keySet := NewKeySet(metadata.JWKSURI)
+	keySet.Client = client
+
+	err = keySet.RefreshWithContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.Issuer = metadata.Issuer
+	c.Keys = keySet
+	if len(c.Algorithms) == 0 {
+		c.Algorithms = supportedAlgorithms(metadata.IDTokenSigningAlgValuesSupported)
+	}
+
+	return nil
+}
+
+// supportedAlgorithms filters algorithms down to those keys can be parsed for, "none" is never included.
+// Nil is returned when no algorithm is left, so DefaultAlgorithms apply.
+func supportedAlgorithms(algorithms []string) []string {
+	var supported []string
+	for _, algorithm := range algorithms {
+		if _, ok := keyTypes[algorithm]; ok {
+			supported = append(supported, algorithm)
+		}
+	}
+
+	return supported
+}
+
+func isAbsoluteURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	return err == nil && parsed.IsAbs() && parsed.Host != ""
+}
@@ -0,0 +1,139 @@
+package authorizer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// createTestDiscoveryServer serves configuration naming issuer, the server URL when issuer is empty.
+func createTestDiscoveryServer(issuer string) *httptest.Server {
+	var testServer *httptest.Server
+	testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			if issuer == "" {
+				issuer = testServer.URL
+			}
+			w.Write([]byte(`{"issuer": "` + issuer + `", "jwks_uri": "` + testServer.URL + `/keys", "id_token_signing_alg_values_supported": ["RS256", "none"]}`))
+		case "/keys":
+			w.Write([]byte(testKeyServerResponseBody))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	return testServer
+}
+
+func createTestDiscoveryToken(issuer string) string {
+	claims := AccessTokenClaims{Scope: "test-scope"}
+	claims.Issuer = issuer
+	claims.TokenUse = "access"
+
+	rsaKey, _ := jwt.ParseRSAPrivateKeyFromPEM([]byte(rawKey))
+	token := jwt.NewWithClaims(jwt.GetSigningMethod("RS256"), claims)
+	token.Header["kid"] = "123456789"
+	tokenString, _ := token.SignedString(rsaKey)
+
+	return tokenString
+}
+
+func TestDiscoverProvider(t *testing.T) {
+	testServer := createTestDiscoveryServer("")
+	defer testServer.Close()
+
+	for _, discoveryURL := range []string{testServer.URL, testServer.URL + "/", testServer.URL + "/.well-known/openid-configuration"} {
+		metadata, err := DiscoverProvider(context.Background(), nil, discoveryURL)
+
+		assert.Nil(t, err)
+		assert.Equal(t, testServer.URL, metadata.Issuer)
+		assert.Equal(t, testServer.URL+"/keys", metadata.JWKSURI)
+	}
+}
+
+func TestDiscoverProviderInvalidMetadata(t *testing.T) {
+	testServer := createTestKeyServer(`{"issuer": "` + testIssuer + `"}`)
+	defer testServer.Close()
+
+	metadata, err := DiscoverProvider(context.Background(), nil, testServer.URL)
+
+	assert.True(t, errors.Is(err, ErrInvalidProviderMetadata))
+	assert.Nil(t, metadata)
+}
+
+func TestDiscoverProviderIssuerMismatch(t *testing.T) {
+	testServer := createTestDiscoveryServer("https://idp.example.com")
+	defer testServer.Close()
+
+	metadata, err := DiscoverProvider(context.Background(), nil, testServer.URL)
+
+	assert.True(t, errors.Is(err, ErrIssuerMismatch))
+	assert.Nil(t, metadata)
+}
+
+func TestDiscoverProviderRequestError(t *testing.T) {
+	testServer := createTestKeyServerWithStatus(500, "")
+	defer testServer.Close()
+
+	_, err := DiscoverProvider(context.Background(), nil, testServer.URL)
+
+	discoveryErr, ok := err.(*DiscoveryError)
+	assert.True(t, ok)
+	assert.Equal(t, 500, discoveryErr.StatusCode)
+	assert.True(t, errors.Is(err, ErrKeyResponseStatus))
+}
+
+func TestContextDiscover(t *testing.T) {
+	testServer := createTestDiscoveryServer("")
+	defer testServer.Close()
+
+	authContext := &Context{}
+	err := authContext.Discover(context.Background(), testServer.Client(), testServer.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, testServer.URL, authContext.ExpectedIssuer())
+	assert.Equal(t, []string{"RS256"}, authContext.Algorithms)
+
+	claims := &AccessTokenClaims{}
+	err = authContext.ParseClaims(createTestDiscoveryToken(testServer.URL), claims)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "test-scope", claims.Scope)
+}
+
+func TestContextDiscoverKeepsAlgorithms(t *testing.T) {
+	testServer := createTestDiscoveryServer("")
+	defer testServer.Close()
+
+	authContext := &Context{Algorithms: []string{"ES256"}}
+	err := authContext.Discover(context.Background(), nil, testServer.URL)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ES256"}, authContext.Algorithms)
+}
+
+func TestContextDiscoverOtherIssuer(t *testing.T) {
+	testServer := createTestDiscoveryServer("")
+	defer testServer.Close()
+
+	authContext := &Context{}
+	err := authContext.Discover(context.Background(), nil, testServer.URL)
+	assert.Nil(t, err)
+
+	err = authContext.ParseClaims(createTestAccessToken("test-scope", "test-subject", nil), &AccessTokenClaims{})
+
+	assert.Equal(t, ErrInvalidIssuer, err)
+}
+
+func TestContextDiscoverUserPools(t *testing.T) {
+	authContext := &Context{UserPools: []*UserPool{NewCognitoUserPool(testRegion, testUserPoolID)}}
+
+	err := authContext.Discover(context.Background(), nil, "https://idp.example.com")
+
+	assert.Equal(t, ErrUserPoolsConfigured, err)
+	assert.Nil(t, authContext.Keys)
+}
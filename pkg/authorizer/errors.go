@@ -0,0 +1,212 @@
+package authorizer
+
+import (
+	"errors"
+	"fmt"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// Errors returned by token verification and response building, compare them with errors.Is.
+var (
+	// ErrUnauthorized is matched by every error returned from ResponseBuilder.
+	ErrUnauthorized = errors.New("Unauthorized")
+	// ErrTokenMissing is returned when request has no token in the configured source.
+	ErrTokenMissing = errors.New("token is missing")
+	// ErrUnsupportedScheme is returned when authorization header uses a scheme other than Bearer, e.g. Basic.
+	ErrUnsupportedScheme = errors.New("authorization scheme is not supported")
+	// ErrTokenTooLarge is returned when authorization header is longer than MaxTokenSize.
+	ErrTokenTooLarge = errors.New("token is too large")
+	// ErrTokenMalformed is returned when token can not be decoded.
+	ErrTokenMalformed = errors.New("token is malformed")
+	// ErrInvalidSignature is returned when token signature does not match the key.
+	ErrInvalidSignature = errors.New("token signature is invalid")
+	// ErrUnknownKeyID is returned when token is signed with a key that is not known.
+	ErrUnknownKeyID = errors.New("token key id is unknown")
+	// ErrKeysUnavailable is returned when keys could not be fetched.
+	ErrKeysUnavailable = errors.New("keys are not available")
+	// ErrTokenExpired is returned when token exp claim is in the past.
+	ErrTokenExpired = errors.New("token is expired")
+	// ErrTokenNotValidYet is returned when token nbf claim is in the future.
+	ErrTokenNotValidYet = errors.New("token is not valid yet")
+	// ErrTokenUsedBeforeIssued is returned when token iat claim is in the future.
+	ErrTokenUsedBeforeIssued = errors.New("token used before issued")
+	// ErrTokenTooOld is returned when token or user authentication is older than the allowed age.
+	ErrTokenTooOld = errors.New("token is too old")
+	// ErrInvalidIssuer is returned when token was not issued by the allowed user pool.
+	ErrInvalidIssuer = errors.New("token issuer is not allowed")
+	// ErrAudienceMismatch is returned when token audience (or client_id of access token) is not an allowed client.
+	ErrAudienceMismatch = errors.New("token audience is not allowed")
+	// ErrTokenUseNotAllowed is returned when token_use claim is not accepted by ResponseBuilder.
+	ErrTokenUseNotAllowed = errors.New("token use is not allowed")
+	// ErrAccessDenied is matched by errors builders return to deny an authenticated caller.
+	ErrAccessDenied = errors.New("access denied")
+	// ErrInvalidMethodARN is returned when method ARN of the authorizer event can not be parsed.
+	ErrInvalidMethodARN = errors.New("method ARN is invalid")
+	// ErrPolicyBuild is returned when PolicyBuilder fails.
+	ErrPolicyBuild = errors.New("failed to build policy")
+	// ErrResponseTooLarge is returned when authorizer response is larger than ResponseBuilder.MaxResponseSize after compaction.
+	ErrResponseTooLarge = errors.New("response is too large")
+	// ErrResponseMarshal is returned when authorizer response can not be serialized, e.g. context holds a channel.
+	ErrResponseMarshal = errors.New("failed to marshal response")
+	// ErrContextBuild is returned when ContextBuilder fails.
+	ErrContextBuild = errors.New("failed to build context")
+)
+
+var (
+	// ErrKeyResponseStatus is returned when JWKS endpoint responds with status other than 2xx.
+	ErrKeyResponseStatus = errors.New("unexpected key response status")
+	// ErrKeyResponseTooLarge is returned when JWKS response body exceeds MaxKeyResponseSize.
+	ErrKeyResponseTooLarge = errors.New("key response is too large")
+	// ErrInvalidProviderMetadata is returned when OpenID provider metadata misses issuer or jwks_uri.
+	ErrInvalidProviderMetadata = errors.New("invalid provider metadata")
+	// ErrUserPoolsConfigured is returned by Context.Discover when the context lists UserPools.
+	ErrUserPoolsConfigured = errors.New("context has user pools")
+	// ErrIssuerMismatch is returned when OpenID provider metadata names other issuer than it was discovered from.
+	ErrIssuerMismatch = errors.New("provider issuer does not match")
+)
+
+// AlgorithmError is returned when token signing algorithm is not supported, not allowed or does not match the key.
+type AlgorithmError struct {
+	Algorithm string
+	KeyID     string
+	Reason    string
+}
+
+func (e *AlgorithmError) Error() string {
+	return fmt.Sprintf("signing algorithm %s with key %s: %s", e.Algorithm, e.KeyID, e.Reason)
+}
+
+// KeyRequestError is returned when keys could not be fetched from URL.
+// StatusCode is zero when no response was received.
+type KeyRequestError struct {
+	URL        string
+	StatusCode int
+	Err        error
+}
+
+func (e *KeyRequestError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("request keys from %s: status %d: %s", e.URL, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("request keys from %s: %s", e.URL, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *KeyRequestError) Unwrap() error {
+	return e.Err
+}
+
+// DiscoveryError is returned when OpenID provider configuration could not be fetched or is not valid.
+// StatusCode is zero when no response was received.
+type DiscoveryError struct {
+	URL        string
+	StatusCode int
+	Err        error
+}
+
+func (e *DiscoveryError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("discover provider from %s: status %d: %s", e.URL, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("discover provider from %s: %s", e.URL, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *DiscoveryError) Unwrap() error {
+	return e.Err
+}
+
+// AccessDeniedError is returned by policy builders when the token is valid but the caller is not allowed.
+// ResponseBuilder responds with a Deny policy for Resource, by default the method ARN of the request.
+type AccessDeniedError struct {
+	Reason   string
+	Resource []string
+}
+
+func (e *AccessDeniedError) Error() string {
+	if e.Reason == "" {
+		return ErrAccessDenied.Error()
+	}
+	return fmt.Sprintf("%s: %s", ErrAccessDenied, e.Reason)
+}
+
+// Is reports whether target is ErrAccessDenied.
+func (e *AccessDeniedError) Is(target error) bool {
+	return target == ErrAccessDenied
+}
+
+// UnauthorizedError is returned by ResponseBuilder. Its message is always "Unauthorized",
+// which API Gateway turns into 401 response, while Err keeps the reason.
+type UnauthorizedError struct {
+	Err error
+}
+
+func (e *UnauthorizedError) Error() string {
+	return ErrUnauthorized.Error()
+}
+
+// Unwrap returns the reason.
+func (e *UnauthorizedError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrUnauthorized.
+func (e *UnauthorizedError) Is(target error) bool {
+	return target == ErrUnauthorized
+}
+
+// wrappedError marks err with a sentinel error, both are matched by errors.Is.
+type wrappedError struct {
+	sentinel error
+	err      error
+}
+
+func wrapError(sentinel, err error) error {
+	return &wrappedError{sentinel: sentinel, err: err}
+}
+
+func (e *wrappedError) Error() string {
+	return fmt.Sprintf("%s: %s", e.sentinel, e.err)
+}
+
+func (e *wrappedError) Unwrap() error {
+	return e.err
+}
+
+func (e *wrappedError) Is(target error) bool {
+	return target == e.sentinel
+}
+
+// verificationError translates errors of jwt package into errors of this package.
+// Errors not known to this package are wrapped with ErrTokenMalformed.
+func verificationError(token *jwt.Token, err error) error {
+	validationErr, ok := err.(*jwt.ValidationError)
+	if !ok {
+		return err
+	}
+
+	switch {
+	case validationErr.Errors&jwt.ValidationErrorUnverifiable != 0 && validationErr.Inner != nil:
+		// Raised while looking up the key, already an error of this package.
+		return validationErr.Inner
+	case validationErr.Errors&jwt.ValidationErrorUnverifiable != 0 && token != nil:
+		// Signing method of alg header is not registered.
+		if algorithm, ok := token.Header["alg"].(string); ok {
+			keyID, _ := token.Header["kid"].(string)
+			return &AlgorithmError{Algorithm: algorithm, KeyID: keyID, Reason: "algorithm is not supported"}
+		}
+	case validationErr.Errors&jwt.ValidationErrorMalformed != 0:
+		return wrapError(ErrTokenMalformed, err)
+	case validationErr.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return wrapError(ErrInvalidSignature, err)
+	case validationErr.Errors&jwt.ValidationErrorExpired != 0:
+		return ErrTokenExpired
+	case validationErr.Errors&jwt.ValidationErrorNotValidYet != 0:
+		return ErrTokenNotValidYet
+	case validationErr.Errors&jwt.ValidationErrorIssuedAt != 0:
+		return ErrTokenUsedBeforeIssued
+	}
+
+	return wrapError(ErrTokenMalformed, err)
+}
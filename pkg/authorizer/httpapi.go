@@ -0,0 +1,186 @@
+package authorizer
+
+import (
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	log "github.com/sirupsen/logrus"
+)
+
+// BuildV2SimpleResponse builds a simple response of HTTP API (API Gateway v2, payload format 2.0) authorizer.
+// When PolicyBuilder is set, IsAuthorized tells whether its policy allows invoking the route ARN of the request,
+// so group, scope and policy document rules apply to simple responses as well. Without PolicyBuilder every
+// valid token is authorized. When the request is not authorized the response has IsAuthorized set to false,
+// failures are returned as *UnauthorizedError, return the response with nil error from the handler so
+// API Gateway responds with 403 instead of 500.
+func (b ResponseBuilder) BuildV2SimpleResponse(event events.APIGatewayV2CustomAuthorizerV2Request) (events.APIGatewayV2CustomAuthorizerSimpleResponse, error) {
+	request, err := b.authorizeV2(event)
+	if err != nil {
+		return events.APIGatewayV2CustomAuthorizerSimpleResponse{}, err
+	}
+
+	if b.PolicyBuilder != nil {
+		policy, err := b.policy(request)
+		if err != nil {
+			return events.APIGatewayV2CustomAuthorizerSimpleResponse{}, err
+		}
+
+		if !policyAllows(policy, request.MethodArn) {
+			log.WithFields(log.Fields{
+				"sub":   request.Token.Base.Subject,
+				"route": request.MethodArn,
+			}).Info("Policy does not allow the route.")
+			return events.APIGatewayV2CustomAuthorizerSimpleResponse{}, nil
+		}
+	}
+
+	context, err := b.context(request)
+	if err != nil {
+		return events.APIGatewayV2CustomAuthorizerSimpleResponse{}, err
+	}
+
+	response := events.APIGatewayV2CustomAuthorizerSimpleResponse{
+		IsAuthorized: true,
+		Context:      context,
+	}
+
+	err = b.fitResponse(&response, nil)
+	if err != nil {
+		return events.APIGatewayV2CustomAuthorizerSimpleResponse{}, err
+	}
+
+	return response, nil
+}
+
+// BuildV2PolicyResponse builds an IAM policy response of HTTP API (API Gateway v2, payload format 2.0) authorizer.
+// Returned error message is always "Unauthorized", the reason can be checked with errors.Is and errors.As.
+func (b ResponseBuilder) BuildV2PolicyResponse(event events.APIGatewayV2CustomAuthorizerV2Request) (events.APIGatewayV2CustomAuthorizerIAMPolicyResponse, error) {
+	request, err := b.authorizeV2(event)
+	if err != nil {
+		return events.APIGatewayV2CustomAuthorizerIAMPolicyResponse{}, err
+	}
+
+	policy, err := b.policy(request)
+	if err != nil {
+		return events.APIGatewayV2CustomAuthorizerIAMPolicyResponse{}, err
+	}
+
+	context, err := b.context(request)
+	if err != nil {
+		return events.APIGatewayV2CustomAuthorizerIAMPolicyResponse{}, err
+	}
+
+	response := events.APIGatewayV2CustomAuthorizerIAMPolicyResponse{
+		PrincipalID:    request.Token.Base.Subject,
+		PolicyDocument: policy,
+		Context:        context,
+	}
+
+	err = b.fitResponse(&response, &response.PolicyDocument)
+	if err != nil {
+		return events.APIGatewayV2CustomAuthorizerIAMPolicyResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (b ResponseBuilder) authorizeV2(event events.APIGatewayV2CustomAuthorizerV2Request) (*AuthorizerRequest, error) {
+	encodedToken, err := b.tokenSource().V2Token(event)
+	if err != nil {
+		log.WithField("error", err).Error("Failed to read token from request.")
+		return nil, &UnauthorizedError{Err: err}
+	}
+
+	request := newV2AuthorizerRequest(event)
+	err = b.authorize(encodedToken, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// V2Token returns the token of HTTP API request, cookies are passed separately from headers in payload format 2.0.
+func (s TokenSource) V2Token(event events.APIGatewayV2CustomAuthorizerV2Request) (string, error) {
+	return s.token(event.Headers, event.QueryStringParameters, strings.Join(event.Cookies, "; "))
+}
+
+func newV2AuthorizerRequest(event events.APIGatewayV2CustomAuthorizerV2Request) *AuthorizerRequest {
+	return &AuthorizerRequest{
+		MethodArn:             event.RouteArn,
+		RouteKey:              event.RouteKey,
+		Path:                  event.RawPath,
+		HTTPMethod:            event.RequestContext.HTTP.Method,
+		Headers:               event.Headers,
+		QueryStringParameters: event.QueryStringParameters,
+		PathParameters:        event.PathParameters,
+		StageVariables:        event.StageVariables,
+	}
+}
+
+// policyAllows evaluates policy the way API Gateway does for invoking resource:
+// an explicit deny wins, otherwise a matching allow is needed.
+func policyAllows(policy events.APIGatewayCustomAuthorizerPolicy, resource string) bool {
+	allowed := false
+	for _, statement := range policy.Statement {
+		if !statementMatches(statement, resource) {
+			continue
+		}
+
+		switch {
+		case strings.EqualFold(statement.Effect, "Deny"):
+			return false
+		case strings.EqualFold(statement.Effect, "Allow"):
+			allowed = true
+		}
+	}
+
+	return allowed
+}
+
+func statementMatches(statement events.IAMPolicyStatement, resource string) bool {
+	actionMatches := false
+	for _, action := range statement.Action {
+		actionMatches = actionMatches || globMatch(strings.ToLower(action), "execute-api:invoke")
+	}
+
+	if !actionMatches {
+		return false
+	}
+
+	for _, pattern := range statement.Resource {
+		if globMatch(pattern, resource) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// globMatch matches value against IAM pattern, `*` matches any sequence of characters and `?` any single character.
+func globMatch(pattern, value string) bool {
+	p, v := 0, 0
+	star, next := -1, 0
+
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, v
+			p++
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case star >= 0:
+			next++
+			p, v = star+1, next
+		default:
+			return false
+		}
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
@@ -0,0 +1,185 @@
+package authorizer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func createTestV2Event(token string) events.APIGatewayV2CustomAuthorizerV2Request {
+	event := events.APIGatewayV2CustomAuthorizerV2Request{
+		Version:        "2.0",
+		Type:           "REQUEST",
+		RouteArn:       "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/items/1",
+		RouteKey:       "GET /items/{id}",
+		RawPath:        "/prod/items/1",
+		Headers:        map[string]string{"authorization": "Bearer " + token},
+		PathParameters: map[string]string{"id": "1"},
+	}
+	event.RequestContext.HTTP.Method = "GET"
+
+	return event
+}
+
+func TestBuildV2SimpleResponseOk(t *testing.T) {
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+	isRequest := mock.MatchedBy(func(request *AuthorizerRequest) bool {
+		return request.Token.Raw == token &&
+			request.RouteKey == "GET /items/{id}" &&
+			request.HTTPMethod == "GET" &&
+			request.PathParameters["id"] == "1"
+	})
+
+	contextBuilderMock := new(requestContextBuilderMock)
+	contextBuilderMock.On("BuildContextForRequest", isRequest).Return(map[string]interface{}{"scope": "test-scope"}, nil).Once()
+
+	response, err := createTestResponseBuilder(nil, contextBuilderMock).BuildV2SimpleResponse(createTestV2Event(token))
+
+	assert.Nil(t, err)
+	assert.Equal(t, events.APIGatewayV2CustomAuthorizerSimpleResponse{
+		IsAuthorized: true,
+		Context:      map[string]interface{}{"scope": "test-scope"},
+	}, response)
+	contextBuilderMock.AssertExpectations(t)
+}
+
+func TestBuildV2SimpleResponsePolicy(t *testing.T) {
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+	tests := []struct {
+		name       string
+		policy     events.APIGatewayCustomAuthorizerPolicy
+		authorized bool
+	}{
+		{"allowed route", allowTestPolicy("arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/items/*"), true},
+		{"allowed stage", allowTestPolicy("arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/*"), true},
+		{"other route", allowTestPolicy("arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/POST/items/*"), false},
+		{"explicit deny", events.APIGatewayCustomAuthorizerPolicy{
+			Version: "2012-10-17",
+			Statement: []events.IAMPolicyStatement{
+				allowTestPolicy("arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/*").Statement[0],
+				DenyPolicy("arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/items/?").Statement[0],
+			},
+		}, false},
+		{"denied", DenyPolicy(), false},
+		{"empty", events.APIGatewayCustomAuthorizerPolicy{Version: "2012-10-17"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policyBuilderMock := new(policyBuilderMock)
+			policyBuilderMock.On("BuildPolicy", token).Return(test.policy, nil).Once()
+			contextBuilderMock := new(contextBuilderMock)
+			contextBuilderMock.On("BuildContext", token).Return(map[string]interface{}{}, nil)
+
+			response, err := createTestResponseBuilder(policyBuilderMock, contextBuilderMock).BuildV2SimpleResponse(createTestV2Event(token))
+
+			assert.Nil(t, err)
+			assert.Equal(t, test.authorized, response.IsAuthorized)
+			policyBuilderMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBuildV2SimpleResponsePolicyAccessDenied(t *testing.T) {
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+
+	policyBuilderMock := new(policyBuilderMock)
+	policyBuilderMock.On("BuildPolicy", token).Return(events.APIGatewayCustomAuthorizerPolicy{}, &AccessDeniedError{Reason: "no group"}).Once()
+
+	response, err := createTestResponseBuilder(policyBuilderMock, new(contextBuilderMock)).BuildV2SimpleResponse(createTestV2Event(token))
+
+	assert.Nil(t, err)
+	assert.False(t, response.IsAuthorized)
+}
+
+func TestBuildV2SimpleResponseUnauthorized(t *testing.T) {
+	response, err := createTestResponseBuilder(new(policyBuilderMock), new(contextBuilderMock)).BuildV2SimpleResponse(createTestV2Event("bad-token"))
+
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	assert.True(t, errors.Is(err, ErrTokenMalformed))
+	assert.False(t, response.IsAuthorized)
+}
+
+func TestBuildV2SimpleResponseWithoutBuilders(t *testing.T) {
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+
+	response, err := createTestResponseBuilder(nil, nil).BuildV2SimpleResponse(createTestV2Event(token))
+
+	assert.Nil(t, err)
+	assert.Equal(t, events.APIGatewayV2CustomAuthorizerSimpleResponse{IsAuthorized: true}, response)
+}
+
+func TestBuildV2PolicyResponseWithoutPolicyBuilder(t *testing.T) {
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+
+	_, err := createTestResponseBuilder(nil, nil).BuildV2PolicyResponse(createTestV2Event(token))
+
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	assert.True(t, errors.Is(err, ErrPolicyBuild))
+}
+
+func TestBuildV2PolicyResponseOk(t *testing.T) {
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+	policy := events.APIGatewayCustomAuthorizerPolicy{Version: "2012-10-17"}
+
+	policyBuilderMock := new(policyBuilderMock)
+	policyBuilderMock.On("BuildPolicy", token).Return(policy, nil).Once()
+	contextBuilderMock := new(contextBuilderMock)
+	contextBuilderMock.On("BuildContext", token).Return(map[string]interface{}{}, nil).Once()
+
+	response, err := createTestResponseBuilder(policyBuilderMock, contextBuilderMock).BuildV2PolicyResponse(createTestV2Event(token))
+
+	assert.Nil(t, err)
+	assert.Equal(t, events.APIGatewayV2CustomAuthorizerIAMPolicyResponse{
+		PrincipalID:    "test-subject",
+		PolicyDocument: policy,
+		Context:        map[string]interface{}{},
+	}, response)
+	policyBuilderMock.AssertExpectations(t)
+	contextBuilderMock.AssertExpectations(t)
+}
+
+func TestBuildV2PolicyResponseTokenMissing(t *testing.T) {
+	event := createTestV2Event("")
+	event.Headers = nil
+
+	response, err := createTestResponseBuilder(new(policyBuilderMock), new(contextBuilderMock)).BuildV2PolicyResponse(event)
+
+	assert.True(t, errors.Is(err, ErrTokenMissing))
+	assert.Equal(t, events.APIGatewayV2CustomAuthorizerIAMPolicyResponse{}, response)
+}
+
+func TestGlobMatch(t *testing.T) {
+	assert.True(t, globMatch("*", "arn:aws:execute-api:eu-west-1:1:api/prod/GET/items"))
+	assert.True(t, globMatch("arn:*:api/prod/*/items", "arn:aws:execute-api:eu-west-1:1:api/prod/GET/items"))
+	assert.True(t, globMatch("api/prod/GE?/items*", "api/prod/GET/items/1"))
+	assert.False(t, globMatch("api/prod/GET/items", "api/prod/GET/items/1"))
+	assert.False(t, globMatch("api/prod/GE?", "api/prod/GE"))
+	assert.False(t, globMatch("api/*/POST/*", "api/prod/GET/items"))
+	assert.True(t, globMatch("a*b", "a*cb"))
+	assert.True(t, globMatch("a*", "a*"))
+	assert.False(t, globMatch("a*b", "a*c"))
+}
+
+func allowTestPolicy(resources ...string) events.APIGatewayCustomAuthorizerPolicy {
+	return events.APIGatewayCustomAuthorizerPolicy{
+		Version: "2012-10-17",
+		Statement: []events.IAMPolicyStatement{
+			{Action: []string{"execute-api:Invoke"}, Effect: "Allow", Resource: resources},
+		},
+	}
+}
+
+func TestTokenSourceV2TokenCookie(t *testing.T) {
+	event := events.APIGatewayV2CustomAuthorizerV2Request{
+		Cookies: []string{"theme=dark", "id_token=test-token"},
+	}
+
+	token, err := TokenSource{Cookie: "id_token"}.V2Token(event)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "test-token", token)
+}
@@ -1,209 +1,331 @@
 package authorizer
 
 import (
-	"bytes"
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/base64"
-	"encoding/binary"
+	"context"
+	"crypto/subtle"
 	"encoding/json"
-	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"math/big"
 	"net/http"
+	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
+	log "github.com/sirupsen/logrus"
 )
 
 // BaseTokenClaims is a common structure for token data.
+// Audience replaces the aud field of StandardClaims, so tokens of providers issuing a list of audiences are accepted.
 type BaseTokenClaims struct {
-	TokenUse string `json:"token_use"`
+	TokenUse string   `json:"token_use"`
+	Audience Audience `json:"aud,omitempty"`
 	jwt.StandardClaims
 }
 
-// IDTokenClaims represents claims stored in ID type JW token
+// VerifyAudience compares the aud claim against cmp, one of listed audiences has to match.
+// If required is false, missing aud claim is accepted.
+func (c *BaseTokenClaims) VerifyAudience(cmp string, required bool) bool {
+	if len(c.Audience) == 0 {
+		return !required
+	}
+
+	for _, audience := range c.Audience {
+		if subtle.ConstantTimeCompare([]byte(audience), []byte(cmp)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Audience is the aud claim, a single string in Cognito tokens and a list of strings in tokens of other providers.
+type Audience []string
+
+// UnmarshalJSON decodes the aud claim given as a string or a list of strings.
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var audiences []string
+	if err := json.Unmarshal(data, &audiences); err == nil {
+		*a = audiences
+		return nil
+	}
+
+	var audience string
+	err := json.Unmarshal(data, &audience)
+	if err != nil {
+		return err
+	}
+
+	*a = Audience{audience}
+	return nil
+}
+
+// MarshalJSON encodes a single audience as a string and more of them as a list.
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+
+	return json.Marshal([]string(a))
+}
+
+// IDTokenClaims represents claims stored in ID type JW token.
+// Claims holds all claims, including custom attributes and identities of federated users. It is filled
+// by Verifier, GetIDClaims and Context.ParseClaims, not by json.Unmarshal.
 type IDTokenClaims struct {
-	EmailVerified   bool   `json:"email_verified"`
-	AuthTime        int64  `json:"auth_time"`
-	CognitoUsername string `json:"cognito:username"`
-	GivenName       string `json:"given_name"`
-	Email           string `json:"email"`
+	EmailVerified       bool                   `json:"email_verified"`
+	AuthTime            int64                  `json:"auth_time"`
+	CognitoUsername     string                 `json:"cognito:username"`
+	GivenName           string                 `json:"given_name"`
+	FamilyName          string                 `json:"family_name"`
+	Name                string                 `json:"name"`
+	Email               string                 `json:"email"`
+	PhoneNumber         string                 `json:"phone_number"`
+	PhoneNumberVerified bool                   `json:"phone_number_verified"`
+	Locale              string                 `json:"locale"`
+	Groups              []string               `json:"cognito:groups"`
+	Roles               []string               `json:"cognito:roles"`
+	PreferredRole       string                 `json:"cognito:preferred_role"`
+	Claims              map[string]interface{} `json:"-"`
 	BaseTokenClaims
 }
 
 // AccessTokenClaims represents claims stored in Access type JW token.
+// Access tokens have no audience, ClientID holds the app client the token was issued to.
 type AccessTokenClaims struct {
-	AuthTime int64  `json:"auth_time"`
-	Scope    string `json:"scope"`
-	Username string `json:"username"`
+	AuthTime      int64    `json:"auth_time"`
+	ClientID      string   `json:"client_id"`
+	Scope         string   `json:"scope"`
+	Username      string   `json:"username"`
+	Groups        []string `json:"cognito:groups"`
+	Roles         []string `json:"cognito:roles"`
+	PreferredRole string   `json:"cognito:preferred_role"`
 	BaseTokenClaims
 }
 
+// VerifyClientID compares the client_id claim against cmp.
+func (c *AccessTokenClaims) VerifyClientID(cmp string) bool {
+	return c.ClientID != "" && subtle.ConstantTimeCompare([]byte(c.ClientID), []byte(cmp)) == 1
+}
+
 // JWKey struct holds information about JSON web key.
+// RSA keys use N and Exponent, EC keys use Curve, X and Y, OKP keys use Curve and X.
 type JWKey struct {
 	Algorithm string `json:"alg"`
+	Curve     string `json:"crv"`
 	Exponent  string `json:"e"`
 	KeyID     string `json:"kid"`
 	KeyType   string `json:"kty"`
 	N         string `json:"n"`
 	Use       string `json:"use"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
 }
 
+// DefaultAlgorithms lists signing algorithms accepted when no other list is configured.
+var DefaultAlgorithms = []string{"RS256"}
+
 const cognitoKeyRetrieveURLTemplate = "https://cognito-idp.%s.amazonaws.com/%s/.well-known/jwks.json"
 
+const (
+	// DefaultKeyRequestTimeout bounds time of fetching keys with DefaultHTTPClient.
+	DefaultKeyRequestTimeout = 5 * time.Second
+	// MaxKeyResponseSize is the maximal accepted size of JWKS response body in bytes.
+	MaxKeyResponseSize = 1 << 20
+)
+
+// DefaultHTTPClient is used to fetch keys when no other client is given.
+var DefaultHTTPClient = &http.Client{Timeout: DefaultKeyRequestTimeout}
+
 type jwkResponse struct {
 	Keys []JWKey `json:"keys"`
 }
 
 // GetDecryptionKeys gets JW token description keys from AWS Cognito service.
 func GetDecryptionKeys(region, userPoolID string) ([]JWKey, error) {
+	return GetDecryptionKeysWithContext(context.Background(), nil, region, userPoolID)
+}
+
+// GetDecryptionKeysWithContext gets JW token description keys from AWS Cognito service using the given client.
+func GetDecryptionKeysWithContext(ctx context.Context, client *http.Client, region, userPoolID string) ([]JWKey, error) {
 	url := fmt.Sprintf(cognitoKeyRetrieveURLTemplate, region, userPoolID)
-	return RequestKeys(url)
+	return RequestKeysWithContext(ctx, client, url)
 }
 
 // RequestKeys retrieves decryption keys from external service.
 func RequestKeys(url string) ([]JWKey, error) {
-	response, err := http.Get(url)
+	return RequestKeysWithContext(context.Background(), nil, url)
+}
 
+// RequestKeysWithContext retrieves decryption keys from external service.
+// DefaultHTTPClient is used when client is nil. Responses other than 2xx and bodies
+// larger than MaxKeyResponseSize are rejected, failures are returned as *KeyRequestError.
+func RequestKeysWithContext(ctx context.Context, client *http.Client, url string) ([]JWKey, error) {
+	var tokenResponse jwkResponse
+	err := requestJSON(ctx, client, url, &tokenResponse)
 	if err != nil {
 		return nil, err
 	}
 
-	defer response.Body.Close()
+	keys := make([]JWKey, 0, len(tokenResponse.Keys))
+	for _, key := range tokenResponse.Keys {
+		if !isSupportedKeyType(key.KeyType) {
+			log.WithFields(log.Fields{
+				"kid": key.KeyID,
+				"kty": key.KeyType,
+			}).Warn("Skipping key of unsupported type.")
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// requestJSON fetches JSON document from url and decodes it into v.
+func requestJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	if client == nil {
+		client = DefaultHTTPClient
+	}
 
-	body, err := ioutil.ReadAll(response.Body)
+	request, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return nil, err
+		return &KeyRequestError{URL: url, Err: err}
 	}
 
-	var tokenResponse jwkResponse
-	err = json.Unmarshal(body, &tokenResponse)
+	response, err := client.Do(request.WithContext(ctx))
 	if err != nil {
-		return nil, err
+		return &KeyRequestError{URL: url, Err: err}
 	}
 
-	for _, key := range tokenResponse.Keys {
-		if key.KeyType != "RSA" {
-			return nil, errors.New("key type is not an RSA")
-		}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return &KeyRequestError{URL: url, StatusCode: response.StatusCode, Err: ErrKeyResponseStatus}
 	}
 
-	return tokenResponse.Keys, nil
-}
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, MaxKeyResponseSize+1))
+	if err != nil {
+		return &KeyRequestError{URL: url, StatusCode: response.StatusCode, Err: err}
+	}
 
-// getDecryptionKey searches for key by ID in list of keys.
-func getDecryptionKey(keys []JWKey, keyID string) (*JWKey, error) {
-	for _, jwt := range keys {
-		if jwt.KeyID == keyID {
-			return &jwt, nil
-		}
+	if len(body) > MaxKeyResponseSize {
+		return &KeyRequestError{URL: url, StatusCode: response.StatusCode, Err: ErrKeyResponseTooLarge}
 	}
 
-	return nil, fmt.Errorf("%s key not found", keyID)
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		return &KeyRequestError{URL: url, StatusCode: response.StatusCode, Err: err}
+	}
+
+	return nil
 }
 
-func getKeyForToken(keys []JWKey) func(token *jwt.Token) (interface{}, error) {
+// keyResolver picks keys used to verify the parsed, not yet verified, token payload.
+type keyResolver func(payload *tokenPayload) (KeyProvider, error)
+
+func staticKeyResolver(keys KeyProvider) keyResolver {
+	return func(payload *tokenPayload) (KeyProvider, error) {
+		return keys, nil
+	}
+}
+
+func getKeyForToken(resolveKeys keyResolver, payload *tokenPayload, algorithms []string) func(token *jwt.Token) (interface{}, error) {
 	f := func(token *jwt.Token) (interface{}, error) {
 		keyID, ok := token.Header["kid"].(string)
 		if !ok {
-			return nil, errors.New("key id is not a string")
+			return nil, wrapError(ErrTokenMalformed, errors.New("key id is not a string"))
 		}
 
-		jwk, err := getDecryptionKey(keys, keyID)
-
+		keys, err := resolveKeys(payload)
 		if err != nil {
 			return nil, err
 		}
 
-		pemString, err := convertJWKtoPEMString(*jwk)
+		key, err := keys.GetKey(keyID)
 
 		if err != nil {
 			return nil, err
 		}
 
-		return jwt.ParseRSAPublicKeyFromPEM([]byte(*pemString))
+		err = verifyAlgorithm(token.Method.Alg(), &key.JWKey, algorithms)
+		if err != nil {
+			return nil, err
+		}
+
+		return key.Key, nil
 	}
 	return f
 }
 
-// GetIDClaims fills claims with ID type token data.
-func GetIDClaims(encodedToken string, keys []JWKey, claims *IDTokenClaims) error {
-	_, err := jwt.ParseWithClaims(encodedToken, claims, getKeyForToken(keys))
-	if err != nil {
-		return err
+// verifyAlgorithm checks that token algorithm is allowed and that the key is meant for it.
+func verifyAlgorithm(algorithm string, jwk *JWKey, algorithms []string) error {
+	allowed := false
+	for _, a := range algorithms {
+		allowed = allowed || a == algorithm
+	}
+
+	switch {
+	case !allowed:
+		return &AlgorithmError{Algorithm: algorithm, KeyID: jwk.KeyID, Reason: "algorithm is not allowed"}
+	case jwk.Algorithm != algorithm:
+		return &AlgorithmError{Algorithm: algorithm, KeyID: jwk.KeyID, Reason: "key algorithm is " + jwk.Algorithm}
+	case jwk.Use != "sig":
+		return &AlgorithmError{Algorithm: algorithm, KeyID: jwk.KeyID, Reason: "key is not meant for signatures"}
 	}
 
 	return nil
 }
 
-// GetAccessClaims fills claims with Access type token data.
-func GetAccessClaims(encodedToken string, keys []JWKey, claims *AccessTokenClaims) error {
-	_, err := jwt.ParseWithClaims(encodedToken, claims, getKeyForToken(keys))
+// parseWithClaims verifies token signature and validity period and fills claims, when they are not nil.
+// The payload is decoded once, the returned payload holds all of its claims.
+// Errors of jwt package are translated into errors of this package.
+func parseWithClaims(encodedToken string, claims jwt.Claims, resolveKeys keyResolver, options verifyOptions) (*jwt.Token, *tokenPayload, error) {
+	payload := &tokenPayload{claims: claims}
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.ParseWithClaims(encodedToken, payload, getKeyForToken(resolveKeys, payload, options.algorithms))
+	if err != nil {
+		return token, payload, verificationError(token, err)
+	}
 
+	err = validateTimes(payload, options)
 	if err != nil {
-		return err
+		return token, payload, err
 	}
 
-	return nil
+	return token, payload, nil
 }
 
-// GetStandardClaims fills claims with standard token type data.
-func GetBaseClaims(encodedToken string, keys []JWKey, claims *BaseTokenClaims) error {
-	_, err := jwt.ParseWithClaims(encodedToken, claims, getKeyForToken(keys))
-
+// GetIDClaims fills claims with ID type token data.
+// It does not verify token issuer, use Context.ParseClaims for that.
+func GetIDClaims(encodedToken string, keys []JWKey, claims *IDTokenClaims) error {
+	_, payload, err := parseWithClaims(encodedToken, claims, staticKeyResolver(NewPublicKeys(keys)), verifyOptions{algorithms: DefaultAlgorithms})
 	if err != nil {
 		return err
 	}
 
+	claims.Claims = payload.values
 	return nil
 }
 
-// converts JWK key type to PEM key type.
-func convertJWKtoPEMString(jwk JWKey) (*string, error) {
-	nb, err := base64.RawURLEncoding.DecodeString(jwk.N)
-	if err != nil {
-		return nil, err
-	}
+// GetAccessClaims fills claims with Access type token data.
+func GetAccessClaims(encodedToken string, keys []JWKey, claims *AccessTokenClaims) error {
+	_, _, err := parseWithClaims(encodedToken, claims, staticKeyResolver(NewPublicKeys(keys)), verifyOptions{algorithms: DefaultAlgorithms})
 
-	eb, err := base64.RawURLEncoding.DecodeString(jwk.Exponent)
 	if err != nil {
-		return nil, err
-	}
-
-	if len(eb) > 4 {
-		return nil, errors.New("e is not a uint32")
-	}
-
-	// if byte array has less than four items we need to add leading zeros to match uint32 byte lengths
-	if len(eb) < 4 {
-		ndata := make([]byte, 4)
-		copy(ndata[4-len(eb):], eb)
-		eb = ndata
+		return err
 	}
 
-	e := binary.BigEndian.Uint32(eb)
+	return nil
+}
 
-	pk := &rsa.PublicKey{
-		N: new(big.Int).SetBytes(nb),
-		E: int(e),
-	}
+// GetStandardClaims fills claims with standard token type data.
+func GetBaseClaims(encodedToken string, keys []JWKey, claims *BaseTokenClaims) error {
+	_, _, err := parseWithClaims(encodedToken, claims, staticKeyResolver(NewPublicKeys(keys)), verifyOptions{algorithms: DefaultAlgorithms})
 
-	der, err := x509.MarshalPKIXPublicKey(pk)
 	if err != nil {
-		return nil, err
-	}
-
-	block := &pem.Block{
-		Type:  "RSA PUBLIC KEY",
-		Bytes: der,
+		return err
 	}
 
-	var out bytes.Buffer
-	pem.Encode(&out, block)
-
-	output := out.String()
-
-	return &output, nil
+	return nil
 }
@@ -1,11 +1,15 @@
 package authorizer
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -31,8 +35,12 @@ var testKeyServerResponseBody = `
 }`
 
 func createTestKeyServer(body string) *httptest.Server {
+	return createTestKeyServerWithStatus(200, body)
+}
+
+func createTestKeyServerWithStatus(status int, body string) *httptest.Server {
 	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(200)
+		w.WriteHeader(status)
 		w.Write([]byte(body))
 	}))
 }
@@ -47,7 +55,7 @@ func TestGetDecryptionKey(t *testing.T) {
 	assert.Equal(t, createTestKeys(), keys)
 }
 
-func TestGetDecryptionKeyNonRSAKeyError(t *testing.T) {
+func TestGetDecryptionKeySkipsUnsupportedKeyType(t *testing.T) {
 	body := `
 	{
 		"keys": [
@@ -62,7 +70,7 @@ func TestGetDecryptionKeyNonRSAKeyError(t *testing.T) {
 				"alg": "RS256",
 				"e": "AQAB",
 				"kid": "123456789",
-				"kty": "non-rsa-key",
+				"kty": "oct",
 				"n": "accd",
 				"use": "sig"
 			}
@@ -73,7 +81,45 @@ func TestGetDecryptionKeyNonRSAKeyError(t *testing.T) {
 
 	keys, err := RequestKeys(testServer.URL)
 
-	assert.NotNil(t, err)
+	assert.Nil(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, "abcdefghijklmnopqrsexample=", keys[0].KeyID)
+}
+
+func TestRequestKeysWithContextStatusError(t *testing.T) {
+	testServer := createTestKeyServerWithStatus(503, "<html>Service Unavailable</html>")
+	defer testServer.Close()
+
+	keys, err := RequestKeysWithContext(context.Background(), testServer.Client(), testServer.URL)
+
+	requestErr, ok := err.(*KeyRequestError)
+	assert.True(t, ok)
+	assert.Equal(t, 503, requestErr.StatusCode)
+	assert.Equal(t, testServer.URL, requestErr.URL)
+	assert.True(t, errors.Is(err, ErrKeyResponseStatus))
+	assert.Nil(t, keys)
+}
+
+func TestRequestKeysWithContextResponseTooLarge(t *testing.T) {
+	body := `{"keys": [], "padding": "` + strings.Repeat("a", MaxKeyResponseSize) + `"}`
+	testServer := createTestKeyServer(body)
+	defer testServer.Close()
+
+	keys, err := RequestKeysWithContext(context.Background(), nil, testServer.URL)
+
+	assert.True(t, errors.Is(err, ErrKeyResponseTooLarge))
+	assert.Nil(t, keys)
+}
+
+func TestRequestKeysWithContextCancelled(t *testing.T) {
+	testServer := createTestKeyServer(testKeyServerResponseBody)
+	defer testServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	keys, err := RequestKeysWithContext(ctx, nil, testServer.URL)
+
+	assert.True(t, errors.Is(err, context.Canceled))
 	assert.Nil(t, keys)
 }
 
@@ -89,6 +135,7 @@ func TestGetIDClaims(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, testEmail, idClaims.Email)
 	assert.Equal(t, testSubject, idClaims.StandardClaims.Subject)
+	assert.Equal(t, testEmail, idClaims.Claims["email"])
 }
 
 func TestGetIDClaimsExpired(t *testing.T) {
@@ -130,6 +177,127 @@ func TestGetBaseClaims(t *testing.T) {
 
 	assert.Nil(t, err)
 	assert.Equal(t, testUse, baseClaims.TokenUse)
-	assert.Equal(t, testAudience, baseClaims.Audience)
+	assert.Equal(t, Audience{testAudience}, baseClaims.Audience)
 	assert.Equal(t, testSubject, baseClaims.Subject)
 }
+
+func TestContextParseClaimsIssuer(t *testing.T) {
+	context := &Context{
+		Region:            testRegion,
+		AllowedUserPoolID: testUserPoolID,
+		DecryptionKeys:    createTestKeys(),
+	}
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+
+	err := context.ParseClaims(token, &AccessTokenClaims{})
+	assert.Nil(t, err)
+
+	context.AllowedUserPoolID = "eu-west-1_other"
+	err = context.ParseClaims(token, &AccessTokenClaims{})
+	assert.Equal(t, ErrInvalidIssuer, err)
+}
+
+type testPlainClaims struct {
+	Iss string `json:"iss"`
+	Sub string `json:"sub"`
+}
+
+func (c *testPlainClaims) Valid() error {
+	return nil
+}
+
+func TestContextParseClaimsIssuerPlainClaims(t *testing.T) {
+	context := &Context{
+		Region:            testRegion,
+		AllowedUserPoolID: "eu-west-1_other",
+		DecryptionKeys:    createTestKeys(),
+	}
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+
+	err := context.ParseClaims(token, &testPlainClaims{})
+	assert.Equal(t, ErrInvalidIssuer, err)
+
+	context.AllowedUserPoolID = testUserPoolID
+	claims := &testPlainClaims{}
+	err = context.ParseClaims(token, claims)
+	assert.Nil(t, err)
+	assert.Equal(t, "test-subject", claims.Sub)
+}
+
+func TestGetAccessClaimsAlgorithmNotAllowed(t *testing.T) {
+	testKeys := createTestKeys()
+	testKeys[1].Algorithm = "RS512"
+	claims := AccessTokenClaims{}
+	claims.TokenUse = "access"
+
+	rsaKey, _ := jwt.ParseRSAPrivateKeyFromPEM([]byte(rawKey))
+	token := jwt.NewWithClaims(jwt.GetSigningMethod("RS512"), claims)
+	token.Header["kid"] = "123456789"
+	tokenString, _ := token.SignedString(rsaKey)
+
+	err := GetAccessClaims(tokenString, testKeys, &AccessTokenClaims{})
+
+	algorithmErr, ok := err.(*AlgorithmError)
+	assert.True(t, ok)
+	assert.Equal(t, "RS512", algorithmErr.Algorithm)
+}
+
+func TestGetAccessClaimsKeyAlgorithmMismatch(t *testing.T) {
+	testKeys := createTestKeys()
+	testKeys[1].Algorithm = "RS512"
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+
+	err := GetAccessClaims(token, testKeys, &AccessTokenClaims{})
+
+	_, ok := err.(*AlgorithmError)
+	assert.True(t, ok)
+}
+
+func TestGetAccessClaimsKeyUseMismatch(t *testing.T) {
+	testKeys := createTestKeys()
+	testKeys[1].Use = "enc"
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+
+	err := GetAccessClaims(token, testKeys, &AccessTokenClaims{})
+
+	_, ok := err.(*AlgorithmError)
+	assert.True(t, ok)
+}
+
+func TestVerifyUnsupportedAlgorithm(t *testing.T) {
+	header := jwt.EncodeSegment([]byte(`{"alg":"FOO","kid":"123456789","typ":"JWT"}`))
+	payload := jwt.EncodeSegment([]byte(`{"iss":"` + testIssuer + `","token_use":"access"}`))
+
+	_, err := createTestVerifier(testClientID).Verify(header + "." + payload + ".c2lnbmF0dXJl")
+
+	algorithmErr, ok := err.(*AlgorithmError)
+	assert.True(t, ok)
+	assert.Equal(t, "FOO", algorithmErr.Algorithm)
+
+	header = jwt.EncodeSegment([]byte(`{"kid":"123456789","typ":"JWT"}`))
+	_, err = createTestVerifier(testClientID).Verify(header + "." + payload + ".c2lnbmF0dXJl")
+
+	assert.True(t, errors.Is(err, ErrTokenMalformed))
+}
+
+func TestContextParseClaimsAlgorithms(t *testing.T) {
+	testKeys := createTestKeys()
+	testKeys[1].Algorithm = "RS512"
+	context := &Context{
+		Region:            testRegion,
+		AllowedUserPoolID: testUserPoolID,
+		DecryptionKeys:    testKeys,
+		Algorithms:        []string{"RS256", "RS512"},
+	}
+	claims := AccessTokenClaims{}
+	claims.Issuer = testIssuer
+
+	rsaKey, _ := jwt.ParseRSAPrivateKeyFromPEM([]byte(rawKey))
+	token := jwt.NewWithClaims(jwt.GetSigningMethod("RS512"), claims)
+	token.Header["kid"] = "123456789"
+	tokenString, _ := token.SignedString(rsaKey)
+
+	err := context.ParseClaims(tokenString, &AccessTokenClaims{})
+
+	assert.Nil(t, err)
+}
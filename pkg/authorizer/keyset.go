@@ -0,0 +1,147 @@
+package authorizer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultKeySetTTL is how long fetched keys are used before they are fetched again.
+	DefaultKeySetTTL = time.Hour
+	// DefaultKeySetRefreshInterval is the minimal time between two fetches triggered by unknown key IDs.
+	DefaultKeySetRefreshInterval = time.Minute
+)
+
+// KeyProvider provides public keys used to verify token signatures.
+type KeyProvider interface {
+	GetKey(keyID string) (*PublicKey, error)
+}
+
+// KeySet is a KeyProvider that fetches keys from JWKS URL and keeps them for TTL.
+// When a token is signed with unknown key ID the keys are fetched again, but not
+// more often than once per MinRefreshInterval, so rotated keys are picked up
+// without restarting the process. Zero TTL and MinRefreshInterval mean DefaultKeySetTTL
+// and DefaultKeySetRefreshInterval. Client is used for fetching, DefaultHTTPClient when nil.
+type KeySet struct {
+	URL                string
+	TTL                time.Duration
+	MinRefreshInterval time.Duration
+	Client             *http.Client
+
+	mu          sync.Mutex
+	keys        PublicKeys
+	fetchedAt   time.Time
+	attemptedAt time.Time
+
+	requestKeys func(ctx context.Context, client *http.Client, url string) ([]JWKey, error)
+	now         func() time.Time
+}
+
+// NewKeySet creates a key set fetching keys from the given JWKS URL.
+func NewKeySet(url string) *KeySet {
+	return &KeySet{
+		URL:                url,
+		TTL:                DefaultKeySetTTL,
+		MinRefreshInterval: DefaultKeySetRefreshInterval,
+	}
+}
+
+// NewCognitoKeySet creates a key set fetching keys of AWS Cognito user pool.
+func NewCognitoKeySet(region, userPoolID string) *KeySet {
+	return NewKeySet(fmt.Sprintf(cognitoKeyRetrieveURLTemplate, region, userPoolID))
+}
+
+// GetKey returns key by ID, fetching keys if they are expired or the ID is unknown.
+func (s *KeySet) GetKey(keyID string) (*PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.currentTime()
+	if (s.keys == nil || now.Sub(s.fetchedAt) >= s.ttl()) && s.canRefresh(now) {
+		s.refresh(context.Background(), now)
+	}
+
+	if s.keys == nil {
+		return nil, wrapError(ErrKeysUnavailable, fmt.Errorf("keys from %s were not fetched", s.URL))
+	}
+
+	key, err := s.keys.GetKey(keyID)
+	if err == nil || !s.canRefresh(now) {
+		return key, err
+	}
+
+	log.WithField("kid", keyID).Info("Unknown key id, refreshing keys.")
+	if s.refresh(context.Background(), now) != nil {
+		return nil, err
+	}
+
+	return s.keys.GetKey(keyID)
+}
+
+// Refresh fetches keys regardless of TTL.
+func (s *KeySet) Refresh() error {
+	return s.RefreshWithContext(context.Background())
+}
+
+// RefreshWithContext fetches keys regardless of TTL, the request is cancelled with ctx.
+func (s *KeySet) RefreshWithContext(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.refresh(ctx, s.currentTime())
+}
+
+// refresh fetches keys, on failure previously fetched keys are kept. Caller must hold the lock.
+func (s *KeySet) refresh(ctx context.Context, now time.Time) error {
+	s.attemptedAt = now
+
+	requestKeys := s.requestKeys
+	if requestKeys == nil {
+		requestKeys = RequestKeysWithContext
+	}
+
+	keys, err := requestKeys(ctx, s.Client, s.URL)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+			"url":   s.URL,
+		}).Error("Failed to refresh decryption keys.")
+		return err
+	}
+
+	s.keys = NewPublicKeys(keys)
+	s.fetchedAt = now
+
+	return nil
+}
+
+// canRefresh tells whether enough time passed since the last fetch attempt.
+func (s *KeySet) canRefresh(now time.Time) bool {
+	return s.attemptedAt.IsZero() || now.Sub(s.attemptedAt) >= s.refreshInterval()
+}
+
+func (s *KeySet) ttl() time.Duration {
+	if s.TTL > 0 {
+		return s.TTL
+	}
+	return DefaultKeySetTTL
+}
+
+func (s *KeySet) refreshInterval() time.Duration {
+	if s.MinRefreshInterval > 0 {
+		return s.MinRefreshInterval
+	}
+	return DefaultKeySetRefreshInterval
+}
+
+func (s *KeySet) currentTime() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,152 @@
+package authorizer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type keySetRequestStub struct {
+	keys  []JWKey
+	err   error
+	calls int
+}
+
+func (s *keySetRequestStub) requestKeys(ctx context.Context, client *http.Client, url string) ([]JWKey, error) {
+	s.calls++
+	return s.keys, s.err
+}
+
+func createTestKeySet(stub *keySetRequestStub, now *time.Time) *KeySet {
+	keySet := NewKeySet("https://example.com/.well-known/jwks.json")
+	keySet.requestKeys = stub.requestKeys
+	keySet.now = func() time.Time { return *now }
+	return keySet
+}
+
+func TestKeySetGetKeyCached(t *testing.T) {
+	now := time.Now()
+	stub := &keySetRequestStub{keys: createTestKeys()}
+	keySet := createTestKeySet(stub, &now)
+
+	key, err := keySet.GetKey("123456789")
+	assert.Nil(t, err)
+	assert.Equal(t, "123456789", key.KeyID)
+
+	now = now.Add(DefaultKeySetTTL - time.Second)
+	_, err = keySet.GetKey("123456789")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, stub.calls)
+}
+
+func TestKeySetGetKeyExpired(t *testing.T) {
+	now := time.Now()
+	stub := &keySetRequestStub{keys: createTestKeys()}
+	keySet := createTestKeySet(stub, &now)
+
+	keySet.GetKey("123456789")
+	now = now.Add(DefaultKeySetTTL)
+	_, err := keySet.GetKey("123456789")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, stub.calls)
+}
+
+func TestKeySetGetKeyExpiredRefreshError(t *testing.T) {
+	now := time.Now()
+	stub := &keySetRequestStub{keys: createTestKeys()}
+	keySet := createTestKeySet(stub, &now)
+
+	keySet.GetKey("123456789")
+	now = now.Add(DefaultKeySetTTL)
+	stub.keys, stub.err = nil, errors.New("error")
+	key, err := keySet.GetKey("123456789")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "123456789", key.KeyID)
+	assert.Equal(t, 2, stub.calls)
+}
+
+func TestKeySetGetKeyUnknownKeyID(t *testing.T) {
+	now := time.Now()
+	stub := &keySetRequestStub{keys: createTestKeys()[:1]}
+	keySet := createTestKeySet(stub, &now)
+
+	_, err := keySet.GetKey("123456789")
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, stub.calls)
+
+	now = now.Add(DefaultKeySetRefreshInterval)
+	stub.keys = createTestKeys()
+	key, err := keySet.GetKey("123456789")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "123456789", key.KeyID)
+	assert.Equal(t, 2, stub.calls)
+}
+
+func TestKeySetGetKeyUnknownKeyIDRateLimited(t *testing.T) {
+	now := time.Now()
+	stub := &keySetRequestStub{keys: createTestKeys()}
+	keySet := createTestKeySet(stub, &now)
+
+	for i := 0; i < 5; i++ {
+		_, err := keySet.GetKey("unknown")
+		assert.NotNil(t, err)
+		now = now.Add(time.Second)
+	}
+
+	assert.Equal(t, 1, stub.calls)
+}
+
+func TestKeySetZeroValueUsesDefaults(t *testing.T) {
+	now := time.Now()
+	stub := &keySetRequestStub{keys: createTestKeys()}
+	keySet := &KeySet{URL: "https://example.com/.well-known/jwks.json", requestKeys: stub.requestKeys}
+	keySet.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		_, err := keySet.GetKey("123456789")
+		assert.Nil(t, err)
+		_, err = keySet.GetKey("unknown")
+		assert.NotNil(t, err)
+		now = now.Add(time.Second)
+	}
+
+	assert.Equal(t, 1, stub.calls)
+}
+
+func TestKeySetGetKeyRequestError(t *testing.T) {
+	now := time.Now()
+	stub := &keySetRequestStub{err: errors.New("error")}
+	keySet := createTestKeySet(stub, &now)
+
+	_, err := keySet.GetKey("123456789")
+	assert.NotNil(t, err)
+	_, err = keySet.GetKey("123456789")
+	assert.NotNil(t, err)
+
+	assert.Equal(t, 1, stub.calls)
+}
+
+func TestParseClaimsWithKeySet(t *testing.T) {
+	testServer := createTestKeyServer(testKeyServerResponseBody)
+	defer testServer.Close()
+
+	context := &Context{
+		Region:            testRegion,
+		AllowedUserPoolID: testUserPoolID,
+		Keys:              NewKeySet(testServer.URL),
+	}
+	claims := &AccessTokenClaims{}
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+
+	err := context.ParseClaims(token, claims)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "test-scope", claims.Scope)
+}
@@ -0,0 +1,109 @@
+package authorizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+const methodARNService = "execute-api"
+
+// MethodARN is the ARN of API Gateway method passed to authorizers as methodArn (routeArn for HTTP APIs):
+// `arn:<partition>:execute-api:<region>:<account>:<api id>/<stage>/<method>/<resource path>`.
+// Resource is the path with leading slash, `*` fields match any value.
+type MethodARN struct {
+	Partition string
+	Region    string
+	AccountID string
+	APIID     string
+	Stage     string
+	Method    string
+	Resource  string
+}
+
+// ParseMethodARN parses method ARN of the authorizer event.
+func ParseMethodARN(arn string) (MethodARN, error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != methodARNService {
+		return MethodARN{}, wrapError(ErrInvalidMethodARN, fmt.Errorf("%q is not an execute-api ARN", arn))
+	}
+
+	path := strings.SplitN(parts[5], "/", 4)
+	if len(path) < 3 {
+		return MethodARN{}, wrapError(ErrInvalidMethodARN, fmt.Errorf("%q has no stage and method", arn))
+	}
+
+	resource := "/"
+	if len(path) == 4 {
+		resource += path[3]
+	}
+
+	return MethodARN{
+		Partition: parts[1],
+		Region:    parts[3],
+		AccountID: parts[4],
+		APIID:     path[0],
+		Stage:     path[1],
+		Method:    path[2],
+		Resource:  resource,
+	}, nil
+}
+
+// NewAPIARN returns ARN matching all methods of the API stage, e.g. for ARNs built from Context.
+// Partition is derived from region and account is `*`.
+func NewAPIARN(region, apiID, stage string) MethodARN {
+	return MethodARN{
+		Partition: PartitionForRegion(region),
+		Region:    region,
+		AccountID: "*",
+		APIID:     apiID,
+		Stage:     stage,
+		Method:    "*",
+		Resource:  "/*",
+	}
+}
+
+// PartitionForRegion returns AWS partition of the region: aws-cn for China, aws-us-gov for GovCloud and aws otherwise.
+func PartitionForRegion(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	}
+	return "aws"
+}
+
+// WithRoute returns ARN of another method and resource of the same API stage, e.g. to build policy resources.
+func (a MethodARN) WithRoute(method, resource string) MethodARN {
+	a.Method = method
+	a.Resource = resource
+	return a
+}
+
+// Matches tells whether the ARN matches IAM resource pattern, e.g. a resource of a policy statement.
+func (a MethodARN) Matches(pattern string) bool {
+	return globMatch(pattern, a.String())
+}
+
+// String formats the ARN, empty method and resource are formatted as `*`.
+func (a MethodARN) String() string {
+	partition := a.Partition
+	if partition == "" {
+		partition = PartitionForRegion(a.Region)
+	}
+
+	method := a.Method
+	if method == "" {
+		method = "*"
+	}
+
+	resource := strings.TrimPrefix(a.Resource, "/")
+	if a.Resource == "" {
+		resource = "*"
+	}
+
+	return fmt.Sprintf(
+		"arn:%s:%s:%s:%s:%s/%s/%s/%s",
+		partition, methodARNService, a.Region, a.AccountID, a.APIID, a.Stage, method, resource,
+	)
+}
@@ -0,0 +1,75 @@
+package authorizer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMethodARN(t *testing.T) {
+	tests := []struct {
+		name string
+		arn  string
+		want MethodARN
+	}{
+		{
+			name: "resource",
+			arn:  "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/items/1",
+			want: MethodARN{Partition: "aws", Region: "eu-west-1", AccountID: "123456789012", APIID: "api-id", Stage: "prod", Method: "GET", Resource: "/items/1"},
+		},
+		{
+			name: "root",
+			arn:  "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/",
+			want: MethodARN{Partition: "aws", Region: "eu-west-1", AccountID: "123456789012", APIID: "api-id", Stage: "prod", Method: "GET", Resource: "/"},
+		},
+		{
+			name: "china",
+			arn:  "arn:aws-cn:execute-api:cn-north-1:123456789012:api-id/$default/POST/items",
+			want: MethodARN{Partition: "aws-cn", Region: "cn-north-1", AccountID: "123456789012", APIID: "api-id", Stage: "$default", Method: "POST", Resource: "/items"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			arn, err := ParseMethodARN(tt.arn)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, arn)
+			assert.Equal(t, tt.arn, arn.String())
+		})
+	}
+}
+
+func TestParseMethodARNInvalid(t *testing.T) {
+	for _, arn := range []string{
+		"",
+		"arn:aws:lambda:eu-west-1:123456789012:function:authorizer",
+		"arn:aws:execute-api:eu-west-1:123456789012:api-id/prod",
+	} {
+		_, err := ParseMethodARN(arn)
+
+		assert.True(t, errors.Is(err, ErrInvalidMethodARN), arn)
+	}
+}
+
+func TestMethodARNWithRoute(t *testing.T) {
+	arn, _ := ParseMethodARN("arn:aws-us-gov:execute-api:us-gov-west-1:123456789012:api-id/prod/GET/items/1")
+
+	assert.Equal(t, "arn:aws-us-gov:execute-api:us-gov-west-1:123456789012:api-id/prod/*/items/*", arn.WithRoute("*", "/items/*").String())
+	assert.Equal(t, "arn:aws-us-gov:execute-api:us-gov-west-1:123456789012:api-id/prod/*/*", arn.WithRoute("", "").String())
+}
+
+func TestNewAPIARN(t *testing.T) {
+	assert.Equal(t, "arn:aws:execute-api:eu-west-1:*:api-id/prod/*/*", NewAPIARN("eu-west-1", "api-id", "prod").String())
+	assert.Equal(t, "arn:aws-cn:execute-api:cn-northwest-1:*:api-id/prod/*/*", NewAPIARN("cn-northwest-1", "api-id", "prod").String())
+	assert.Equal(t, "arn:aws-us-gov:execute-api:us-gov-east-1:*:api-id/prod/*/*", NewAPIARN("us-gov-east-1", "api-id", "prod").String())
+}
+
+func TestMethodARNMatches(t *testing.T) {
+	arn, _ := ParseMethodARN("arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/tenants/acme")
+
+	assert.True(t, arn.Matches("arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/*/tenants/*"))
+	assert.True(t, arn.Matches("arn:aws:execute-api:eu-west-1:*:api-id/prod/GET/tenants/acme"))
+	assert.False(t, arn.Matches("arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/*/items/*"))
+}
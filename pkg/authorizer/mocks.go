@@ -22,3 +22,39 @@ func (m *contextBuilderMock) BuildContext(encodedToken string) (map[string]inter
 	args := m.Called(encodedToken)
 	return args.Get(0).(map[string]interface{}), args.Error(1)
 }
+
+type tokenPolicyBuilderMock struct {
+	policyBuilderMock
+}
+
+func (m *tokenPolicyBuilderMock) BuildPolicyForToken(token *VerifiedToken) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	args := m.Called(token)
+	return args.Get(0).(events.APIGatewayCustomAuthorizerPolicy), args.Error(1)
+}
+
+type tokenContextBuilderMock struct {
+	contextBuilderMock
+}
+
+func (m *tokenContextBuilderMock) BuildContextForToken(token *VerifiedToken) (map[string]interface{}, error) {
+	args := m.Called(token)
+	return args.Get(0).(map[string]interface{}), args.Error(1)
+}
+
+type requestPolicyBuilderMock struct {
+	policyBuilderMock
+}
+
+func (m *requestPolicyBuilderMock) BuildPolicyForRequest(request *AuthorizerRequest) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	args := m.Called(request)
+	return args.Get(0).(events.APIGatewayCustomAuthorizerPolicy), args.Error(1)
+}
+
+type requestContextBuilderMock struct {
+	contextBuilderMock
+}
+
+func (m *requestContextBuilderMock) BuildContextForRequest(request *AuthorizerRequest) (map[string]interface{}, error) {
+	args := m.Called(request)
+	return args.Get(0).(map[string]interface{}), args.Error(1)
+}
@@ -0,0 +1,186 @@
+package authorizer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MinRSAKeySize is the minimal accepted size of RSA key modulus in bits.
+var MinRSAKeySize = 2048
+
+// keyTypes maps signing algorithms to key type and curve they need.
+var keyTypes = map[string]struct{ keyType, curve string }{
+	"RS256": {"RSA", ""},
+	"RS384": {"RSA", ""},
+	"RS512": {"RSA", ""},
+	"PS256": {"RSA", ""},
+	"PS384": {"RSA", ""},
+	"PS512": {"RSA", ""},
+	"ES256": {"EC", "P-256"},
+	"ES384": {"EC", "P-384"},
+	"ES512": {"EC", "P-521"},
+	"EdDSA": {"OKP", "Ed25519"},
+}
+
+var curves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+// PublicKey is a JW key with its key material decoded.
+// Key is *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey depending on the key type.
+type PublicKey struct {
+	JWKey
+	Key crypto.PublicKey
+}
+
+// ParsePublicKey decodes and validates key material of JW key.
+func ParsePublicKey(jwk JWKey) (*PublicKey, error) {
+	if expected, ok := keyTypes[jwk.Algorithm]; ok && (expected.keyType != jwk.KeyType || expected.curve != jwk.Curve) {
+		return nil, fmt.Errorf("algorithm %s can not be used with %s %s key", jwk.Algorithm, jwk.Curve, jwk.KeyType)
+	}
+
+	var key crypto.PublicKey
+	var err error
+
+	switch jwk.KeyType {
+	case "RSA":
+		key, err = parseRSAPublicKey(jwk)
+	case "EC":
+		key, err = parseECPublicKey(jwk)
+	case "OKP":
+		key, err = parseOKPPublicKey(jwk)
+	default:
+		err = fmt.Errorf("key type %s is not supported", jwk.KeyType)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &PublicKey{JWKey: jwk, Key: key}, nil
+}
+
+func isSupportedKeyType(keyType string) bool {
+	return keyType == "RSA" || keyType == "EC" || keyType == "OKP"
+}
+
+// PublicKeys is a KeyProvider holding parsed keys by key ID.
+type PublicKeys map[string]*PublicKey
+
+// NewPublicKeys parses list of JW keys. Invalid keys are skipped with a warning.
+func NewPublicKeys(keys []JWKey) PublicKeys {
+	publicKeys := make(PublicKeys, len(keys))
+	for _, jwk := range keys {
+		key, err := ParsePublicKey(jwk)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+				"kid":   jwk.KeyID,
+			}).Warn("Skipping invalid key.")
+			continue
+		}
+		publicKeys[jwk.KeyID] = key
+	}
+
+	return publicKeys
+}
+
+// GetKey searches for key by ID.
+func (k PublicKeys) GetKey(keyID string) (*PublicKey, error) {
+	key, ok := k[keyID]
+	if !ok {
+		return nil, wrapError(ErrUnknownKeyID, fmt.Errorf("%s key not found", keyID))
+	}
+
+	return key, nil
+}
+
+// parseRSAPublicKey decodes modulus and exponent of RSA JW key.
+func parseRSAPublicKey(jwk JWKey) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+
+	eb, err := base64.RawURLEncoding.DecodeString(jwk.Exponent)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(eb) > 4 {
+		return nil, errors.New("e is not a uint32")
+	}
+
+	n := new(big.Int).SetBytes(nb)
+	if n.BitLen() < MinRSAKeySize {
+		return nil, fmt.Errorf("key size %d is less than %d bits", n.BitLen(), MinRSAKeySize)
+	}
+
+	e := new(big.Int).SetBytes(eb).Int64()
+	if e < 3 || e%2 == 0 || e > 1<<31-1 {
+		return nil, fmt.Errorf("exponent %d is not valid", e)
+	}
+
+	return &rsa.PublicKey{N: n, E: int(e)}, nil
+}
+
+// parseECPublicKey decodes point coordinates of EC JW key and checks that the point is on the curve.
+func parseECPublicKey(jwk JWKey) (*ecdsa.PublicKey, error) {
+	curve, ok := curves[jwk.Curve]
+	if !ok {
+		return nil, fmt.Errorf("curve %s is not supported", jwk.Curve)
+	}
+
+	size := (curve.Params().BitSize + 7) / 8
+
+	xb, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, err
+	}
+
+	yb, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(xb) != size || len(yb) != size {
+		return nil, fmt.Errorf("coordinates are not %d bytes long", size)
+	}
+
+	x := new(big.Int).SetBytes(xb)
+	y := new(big.Int).SetBytes(yb)
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on the curve")
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// parseOKPPublicKey decodes public key of OKP JW key, only Ed25519 curve is supported.
+func parseOKPPublicKey(jwk JWKey) (ed25519.PublicKey, error) {
+	if jwk.Curve != "Ed25519" {
+		return nil, fmt.Errorf("curve %s is not supported", jwk.Curve)
+	}
+
+	xb, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(xb) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("key is not %d bytes long", ed25519.PublicKeySize)
+	}
+
+	return ed25519.PublicKey(xb), nil
+}
@@ -0,0 +1,186 @@
+package authorizer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePublicKey(t *testing.T) {
+	jwk := createTestKeys()[1]
+
+	key, err := ParsePublicKey(jwk)
+
+	assert.Nil(t, err)
+	assert.Equal(t, jwk, key.JWKey)
+	rsaKey := key.Key.(*rsa.PublicKey)
+	assert.Equal(t, 2048, rsaKey.N.BitLen())
+	assert.Equal(t, 65537, rsaKey.E)
+}
+
+func TestParsePublicKeyTooSmall(t *testing.T) {
+	key, err := ParsePublicKey(createTestKeys()[0])
+
+	assert.NotNil(t, err)
+	assert.Nil(t, key)
+}
+
+func TestParsePublicKeyInvalidExponent(t *testing.T) {
+	for _, exponent := range []string{"AQ", "Ag", "AQAAAAAB", "!"} {
+		jwk := createTestKeys()[1]
+		jwk.Exponent = exponent
+
+		key, err := ParsePublicKey(jwk)
+
+		assert.NotNil(t, err, exponent)
+		assert.Nil(t, key, exponent)
+	}
+}
+
+func TestNewPublicKeysSkipsInvalidKeys(t *testing.T) {
+	keys := NewPublicKeys(createTestKeys())
+
+	assert.Len(t, keys, 1)
+	key, err := keys.GetKey("123456789")
+	assert.Nil(t, err)
+	assert.Equal(t, "123456789", key.KeyID)
+	_, err = keys.GetKey("abcdefghijklmnopqrsexample=")
+	assert.NotNil(t, err)
+}
+
+func createTestECKey(curve elliptic.Curve, name, algorithm string) (*ecdsa.PrivateKey, JWKey) {
+	privateKey, _ := ecdsa.GenerateKey(curve, rand.Reader)
+	size := (curve.Params().BitSize + 7) / 8
+	x := make([]byte, size)
+	y := make([]byte, size)
+	privateKey.X.FillBytes(x)
+	privateKey.Y.FillBytes(y)
+
+	return privateKey, JWKey{
+		Algorithm: algorithm,
+		Curve:     name,
+		KeyID:     "ec-" + name,
+		KeyType:   "EC",
+		Use:       "sig",
+		X:         base64.RawURLEncoding.EncodeToString(x),
+		Y:         base64.RawURLEncoding.EncodeToString(y),
+	}
+}
+
+func createTestEd25519Key() (ed25519.PrivateKey, JWKey) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	return privateKey, JWKey{
+		Algorithm: "EdDSA",
+		Curve:     "Ed25519",
+		KeyID:     "okp-ed25519",
+		KeyType:   "OKP",
+		Use:       "sig",
+		X:         base64.RawURLEncoding.EncodeToString(publicKey),
+	}
+}
+
+func createTestSignedToken(algorithm, keyID string, key crypto.PrivateKey) string {
+	claims := AccessTokenClaims{Scope: "test-scope"}
+	claims.Issuer = testIssuer
+	claims.TokenUse = "access"
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(algorithm), claims)
+	token.Header["kid"] = keyID
+	tokenString, _ := token.SignedString(key)
+
+	return tokenString
+}
+
+func TestParseClaimsEllipticCurveKeys(t *testing.T) {
+	p256Key, p256JWK := createTestECKey(elliptic.P256(), "P-256", "ES256")
+	p384Key, p384JWK := createTestECKey(elliptic.P384(), "P-384", "ES384")
+	ed25519Key, ed25519JWK := createTestEd25519Key()
+
+	context := &Context{
+		Region:            testRegion,
+		AllowedUserPoolID: testUserPoolID,
+		DecryptionKeys:    []JWKey{p256JWK, p384JWK, ed25519JWK},
+		Algorithms:        []string{"ES256", "ES384", "EdDSA"},
+	}
+
+	tokens := []string{
+		createTestSignedToken("ES256", p256JWK.KeyID, p256Key),
+		createTestSignedToken("ES384", p384JWK.KeyID, p384Key),
+		createTestSignedToken("EdDSA", ed25519JWK.KeyID, ed25519Key),
+	}
+
+	for _, token := range tokens {
+		claims := &AccessTokenClaims{}
+		err := context.ParseClaims(token, claims)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "test-scope", claims.Scope)
+	}
+}
+
+func TestParseClaimsEllipticCurveWrongKey(t *testing.T) {
+	_, p256JWK := createTestECKey(elliptic.P256(), "P-256", "ES256")
+	otherKey, _ := createTestECKey(elliptic.P256(), "P-256", "ES256")
+
+	context := &Context{
+		Region:            testRegion,
+		AllowedUserPoolID: testUserPoolID,
+		DecryptionKeys:    []JWKey{p256JWK},
+		Algorithms:        []string{"ES256"},
+	}
+
+	err := context.ParseClaims(createTestSignedToken("ES256", p256JWK.KeyID, otherKey), &AccessTokenClaims{})
+
+	assert.NotNil(t, err)
+}
+
+func TestParsePublicKeyAlgorithmKeyTypeMismatch(t *testing.T) {
+	_, p256JWK := createTestECKey(elliptic.P256(), "P-256", "ES384")
+	_, ed25519JWK := createTestEd25519Key()
+	ed25519JWK.Algorithm = "ES256"
+	rsaJWK := createTestKeys()[1]
+	rsaJWK.Algorithm = "EdDSA"
+
+	for _, jwk := range []JWKey{p256JWK, ed25519JWK, rsaJWK} {
+		key, err := ParsePublicKey(jwk)
+
+		assert.NotNil(t, err, jwk.KeyID)
+		assert.Nil(t, key, jwk.KeyID)
+	}
+}
+
+func TestParsePublicKeyPointNotOnCurve(t *testing.T) {
+	_, jwk := createTestECKey(elliptic.P256(), "P-256", "ES256")
+	jwk.Y = jwk.X
+
+	key, err := ParsePublicKey(jwk)
+
+	assert.NotNil(t, err)
+	assert.Nil(t, key)
+}
+
+func TestContextKeyProviderDecryptionKeysReplaced(t *testing.T) {
+	context := &Context{
+		Region:            testRegion,
+		AllowedUserPoolID: testUserPoolID,
+		CognitoClients:    []string{testClientID},
+	}
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+
+	_, err := NewVerifier(context).Verify(token)
+	assert.True(t, errors.Is(err, ErrUnknownKeyID))
+
+	context.DecryptionKeys = createTestKeys()
+	_, err = NewVerifier(context).Verify(token)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,147 @@
+package authorizer
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// AuthorizerRequest holds the verified token together with data of the request being authorized.
+// For TOKEN authorizers only Token, MethodArn and HTTPMethod and Path read from the method ARN are set.
+// RouteKey is set only for HTTP API requests, MethodArn holds their route ARN.
+type AuthorizerRequest struct {
+	Token                 *VerifiedToken
+	MethodArn             string
+	Resource              string
+	RouteKey              string
+	Path                  string
+	HTTPMethod            string
+	Headers               map[string]string
+	QueryStringParameters map[string]string
+	PathParameters        map[string]string
+	StageVariables        map[string]string
+}
+
+// RequestPolicyBuilder builds API GW custom authorizer policy from the authorized request.
+// ResponseBuilder prefers it over BuildPolicyForToken and BuildPolicy when PolicyBuilder implements it.
+type RequestPolicyBuilder interface {
+	BuildPolicyForRequest(request *AuthorizerRequest) (events.APIGatewayCustomAuthorizerPolicy, error)
+}
+
+// RequestContextBuilder builds context passed to resource server from the authorized request.
+// ResponseBuilder prefers it over BuildContextForToken and BuildContext when ContextBuilder implements it.
+type RequestContextBuilder interface {
+	BuildContextForRequest(request *AuthorizerRequest) (map[string]interface{}, error)
+}
+
+// TokenSource tells where REQUEST authorizers read the token from.
+// Header, QueryString and Cookie are checked in this order, empty names are skipped.
+// When Prefix is set (e.g. `Bearer `) the value must start with it, the prefix is compared case-insensitively.
+// When Bearer is set the value is read with ExtractToken, so both `Bearer <token>` and a raw token are accepted.
+type TokenSource struct {
+	Header      string
+	QueryString string
+	Cookie      string
+	Prefix      string
+	Bearer      bool
+}
+
+// DefaultTokenSource reads `Authorization` header with `Bearer <token>` or a raw token.
+var DefaultTokenSource = TokenSource{
+	Header: "Authorization",
+	Bearer: true,
+}
+
+// Token returns the token of the request.
+func (s TokenSource) Token(event events.APIGatewayCustomAuthorizerRequestTypeRequest) (string, error) {
+	return s.token(event.Headers, event.QueryStringParameters, headerValue(event.Headers, "Cookie"))
+}
+
+func (s TokenSource) token(headers, queryString map[string]string, cookies string) (string, error) {
+	value := s.value(headers, queryString, cookies)
+	if value == "" {
+		return "", ErrTokenMissing
+	}
+
+	if s.Bearer {
+		return ExtractToken(value)
+	}
+
+	if len(value) > MaxTokenSize {
+		return "", ErrTokenTooLarge
+	}
+
+	if s.Prefix == "" {
+		return value, nil
+	}
+
+	if len(value) <= len(s.Prefix) || !strings.EqualFold(value[:len(s.Prefix)], s.Prefix) {
+		return "", ErrTokenMalformed
+	}
+
+	return value[len(s.Prefix):], nil
+}
+
+func (s TokenSource) value(headers, queryString map[string]string, cookies string) string {
+	if s.Header != "" {
+		if value := headerValue(headers, s.Header); value != "" {
+			return value
+		}
+	}
+
+	if s.QueryString != "" {
+		if value := queryString[s.QueryString]; value != "" {
+			return value
+		}
+	}
+
+	if s.Cookie != "" {
+		request := http.Request{Header: http.Header{"Cookie": {cookies}}}
+		if cookie, err := request.Cookie(s.Cookie); err == nil {
+			return cookie.Value
+		}
+	}
+
+	return ""
+}
+
+// headerValue finds header ignoring case, API Gateway passes header names as sent by the client.
+func headerValue(headers map[string]string, name string) string {
+	if value, ok := headers[name]; ok {
+		return value
+	}
+
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+
+	return ""
+}
+
+func newAuthorizerRequest(event events.APIGatewayCustomAuthorizerRequestTypeRequest) *AuthorizerRequest {
+	return &AuthorizerRequest{
+		MethodArn:             event.MethodArn,
+		Resource:              event.Resource,
+		Path:                  event.Path,
+		HTTPMethod:            event.HTTPMethod,
+		Headers:               event.Headers,
+		QueryStringParameters: event.QueryStringParameters,
+		PathParameters:        event.PathParameters,
+		StageVariables:        event.StageVariables,
+	}
+}
+
+// newTokenAuthorizerRequest reads HTTP method and path from method ARN, TOKEN authorizer events carry nothing else.
+func newTokenAuthorizerRequest(event events.APIGatewayCustomAuthorizerRequest) *AuthorizerRequest {
+	request := &AuthorizerRequest{MethodArn: event.MethodArn}
+
+	if arn, err := ParseMethodARN(event.MethodArn); err == nil {
+		request.HTTPMethod = arn.Method
+		request.Path = arn.Resource
+	}
+
+	return request
+}
@@ -17,47 +17,187 @@ type ContextBuilder interface {
 	BuildContext(encodedToken string) (map[string]interface{}, error)
 }
 
+// TokenPolicyBuilder builds API GW custom authorizer policy from already verified token.
+// ResponseBuilder prefers it over BuildPolicy when PolicyBuilder implements it.
+type TokenPolicyBuilder interface {
+	BuildPolicyForToken(token *VerifiedToken) (events.APIGatewayCustomAuthorizerPolicy, error)
+}
+
+// TokenContextBuilder builds context passed to resource server from already verified token.
+// ResponseBuilder prefers it over BuildContext when ContextBuilder implements it.
+type TokenContextBuilder interface {
+	BuildContextForToken(token *VerifiedToken) (map[string]interface{}, error)
+}
+
 // ResponseBuilder struct for building proper custom authorizer response.
+// Verifier is optional, by default tokens are verified against Context.
+// AllowedTokenUse is checked before builders run, by default any token_use is accepted.
+// TokenSource is used by REQUEST authorizers, DefaultTokenSource when empty.
+// Responses larger than MaxResponseSize (DefaultMaxResponseSize when zero) are compacted with CompactPolicy.
+// ContextBuilder is optional, responses have no context without it. PolicyBuilder is required by responses
+// with a policy, they fail with ErrPolicyBuild without it.
 type ResponseBuilder struct {
-	Context        *Context
-	PolicyBuilder  PolicyBuilder
-	ContextBuilder ContextBuilder
+	Context         *Context
+	Verifier        *Verifier
+	AllowedTokenUse TokenUsePolicy
+	TokenSource     TokenSource
+	MaxResponseSize int
+	PolicyBuilder   PolicyBuilder
+	ContextBuilder  ContextBuilder
 }
 
 // BuildResponse builds a proper custom authorizer response based on context, policy and context builders.
-func (b ResponseBuilder) BuildResponse(encodedToken string) (events.APIGatewayCustomAuthorizerResponse, error) {
-	baseClaims := &BaseTokenClaims{}
-	err := GetBaseClaims(encodedToken, b.Context.DecryptionKeys, baseClaims)
+// When the policy builder denies the caller with ErrAccessDenied (e.g. *AccessDeniedError) the response
+// has a Deny policy, API Gateway responds with 403 and caches the decision.
+// authorizationToken is the token or the whole `Bearer <token>` header value, see ExtractToken.
+// Builders get no method ARN, use BuildTokenResponse to pass the whole TOKEN authorizer event.
+// Returned error message is always "Unauthorized", the reason can be checked with errors.Is and errors.As.
+func (b ResponseBuilder) BuildResponse(authorizationToken string) (events.APIGatewayCustomAuthorizerResponse, error) {
+	encodedToken, err := ExtractToken(authorizationToken)
 	if err != nil {
-		log.WithField("error", err).Info("Failed to get token standard claims.")
-		return events.APIGatewayCustomAuthorizerResponse{}, errors.New("Unauthorized")
+		log.WithField("error", err).Error("Failed to read token from authorization header.")
+		return events.APIGatewayCustomAuthorizerResponse{}, &UnauthorizedError{Err: err}
 	}
 
-	valid := false
-	for _, client := range b.Context.CognitoClients {
-		valid = valid || baseClaims.VerifyAudience(client, true) || baseClaims.TokenUse == "access" // Only ID Token has audience field.
+	return b.respond(encodedToken, &AuthorizerRequest{})
+}
+
+// BuildTokenResponse builds a custom authorizer response for TOKEN authorizers. It works like BuildResponse,
+// but builders also get the method ARN of the event, with HTTP method and path read from it, and Deny
+// policies are limited to the called method instead of `*`. Prefer it over BuildResponse.
+func (b ResponseBuilder) BuildTokenResponse(event events.APIGatewayCustomAuthorizerRequest) (events.APIGatewayCustomAuthorizerResponse, error) {
+	encodedToken, err := ExtractToken(event.AuthorizationToken)
+	if err != nil {
+		log.WithField("error", err).Error("Failed to read token from authorization header.")
+		return events.APIGatewayCustomAuthorizerResponse{}, &UnauthorizedError{Err: err}
 	}
 
-	if !valid {
-		log.WithField("audience", baseClaims.Audience).Error("Failed to verify token audience.")
-		return events.APIGatewayCustomAuthorizerResponse{}, errors.New("Unauthorized")
+	return b.respond(encodedToken, newTokenAuthorizerRequest(event))
+}
+
+// BuildRequestResponse builds a custom authorizer response for REQUEST authorizers.
+// The token is read from TokenSource, builders get headers, path parameters and stage variables of the request.
+func (b ResponseBuilder) BuildRequestResponse(event events.APIGatewayCustomAuthorizerRequestTypeRequest) (events.APIGatewayCustomAuthorizerResponse, error) {
+	encodedToken, err := b.tokenSource().Token(event)
+	if err != nil {
+		log.WithField("error", err).Error("Failed to read token from request.")
+		return events.APIGatewayCustomAuthorizerResponse{}, &UnauthorizedError{Err: err}
 	}
 
-	policy, err := b.PolicyBuilder.BuildPolicy(encodedToken)
+	return b.respond(encodedToken, newAuthorizerRequest(event))
+}
+
+func (b ResponseBuilder) respond(encodedToken string, request *AuthorizerRequest) (events.APIGatewayCustomAuthorizerResponse, error) {
+	err := b.authorize(encodedToken, request)
 	if err != nil {
-		log.WithField("error", err).Error("Failed to build policy document.")
-		return events.APIGatewayCustomAuthorizerResponse{}, errors.New("Unauthorized")
+		return events.APIGatewayCustomAuthorizerResponse{}, err
 	}
 
-	context, err := b.ContextBuilder.BuildContext(encodedToken)
+	policy, err := b.policy(request)
 	if err != nil {
-		log.WithField("error", err).Error("Failed to build context.")
-		return events.APIGatewayCustomAuthorizerResponse{}, errors.New("Unauthorized")
+		return events.APIGatewayCustomAuthorizerResponse{}, err
 	}
 
-	return events.APIGatewayCustomAuthorizerResponse{
-		PrincipalID:    baseClaims.Subject,
+	context, err := b.context(request)
+	if err != nil {
+		return events.APIGatewayCustomAuthorizerResponse{}, err
+	}
+
+	response := events.APIGatewayCustomAuthorizerResponse{
+		PrincipalID:    request.Token.Base.Subject,
 		PolicyDocument: policy,
 		Context:        context,
-	}, nil
+	}
+
+	err = b.fitResponse(&response, &response.PolicyDocument)
+	if err != nil {
+		return events.APIGatewayCustomAuthorizerResponse{}, err
+	}
+
+	return response, nil
+}
+
+// authorize verifies the token and its use, the verified token is stored in the request.
+func (b ResponseBuilder) authorize(encodedToken string, request *AuthorizerRequest) error {
+	token, err := b.verifier().Verify(encodedToken)
+	if err != nil {
+		return &UnauthorizedError{Err: err}
+	}
+	request.Token = token
+
+	if !b.AllowedTokenUse.Allows(token.Base.TokenUse) {
+		log.WithFields(log.Fields{
+			"token_use": token.Base.TokenUse,
+			"allowed":   b.AllowedTokenUse.String(),
+		}).Error("Token use is not allowed.")
+		return &UnauthorizedError{Err: ErrTokenUseNotAllowed}
+	}
+
+	return nil
+}
+
+func (b ResponseBuilder) policy(request *AuthorizerRequest) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	policy, err := b.buildPolicy(request)
+	if denied, ok := deniedPolicy(err, request); ok {
+		log.WithFields(log.Fields{
+			"error": err,
+			"sub":   request.Token.Base.Subject,
+		}).Info("Access denied.")
+		return denied, nil
+	}
+
+	if err != nil {
+		log.WithField("error", err).Error("Failed to build policy document.")
+		return events.APIGatewayCustomAuthorizerPolicy{}, &UnauthorizedError{Err: wrapError(ErrPolicyBuild, err)}
+	}
+
+	return policy, nil
+}
+
+func (b ResponseBuilder) context(request *AuthorizerRequest) (map[string]interface{}, error) {
+	context, err := b.buildContext(request)
+	if err != nil {
+		log.WithField("error", err).Error("Failed to build context.")
+		return nil, &UnauthorizedError{Err: wrapError(ErrContextBuild, err)}
+	}
+
+	return context, nil
+}
+
+func (b ResponseBuilder) verifier() *Verifier {
+	if b.Verifier != nil {
+		return b.Verifier
+	}
+	return NewVerifier(b.Context)
+}
+
+func (b ResponseBuilder) tokenSource() TokenSource {
+	if b.TokenSource != (TokenSource{}) {
+		return b.TokenSource
+	}
+	return DefaultTokenSource
+}
+
+func (b ResponseBuilder) buildPolicy(request *AuthorizerRequest) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	switch builder := b.PolicyBuilder.(type) {
+	case nil:
+		return events.APIGatewayCustomAuthorizerPolicy{}, errors.New("policy builder is not set")
+	case RequestPolicyBuilder:
+		return builder.BuildPolicyForRequest(request)
+	case TokenPolicyBuilder:
+		return builder.BuildPolicyForToken(request.Token)
+	}
+	return b.PolicyBuilder.BuildPolicy(request.Token.Raw)
+}
+
+func (b ResponseBuilder) buildContext(request *AuthorizerRequest) (map[string]interface{}, error) {
+	switch builder := b.ContextBuilder.(type) {
+	case nil:
+		return nil, nil
+	case RequestContextBuilder:
+		return builder.BuildContextForRequest(request)
+	case TokenContextBuilder:
+		return builder.BuildContextForToken(request.Token)
+	}
+	return b.ContextBuilder.BuildContext(request.Token.Raw)
 }
@@ -3,11 +3,26 @@ package authorizer
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
+func createTestResponseBuilder(policyBuilder PolicyBuilder, contextBuilder ContextBuilder) ResponseBuilder {
+	return ResponseBuilder{
+		Context: &Context{
+			Region:            testRegion,
+			AllowedUserPoolID: testUserPoolID,
+			DecryptionKeys:    createTestKeys(),
+			CognitoClients:    []string{testClientID},
+		},
+		PolicyBuilder:  policyBuilder,
+		ContextBuilder: contextBuilder,
+	}
+}
+
 func TestBuildResponseOk(t *testing.T) {
 	testEmail := "test@example.com"
 	testSubject := "test-subject"
@@ -22,8 +37,10 @@ func TestBuildResponseOk(t *testing.T) {
 
 	responseBuilder := ResponseBuilder{
 		Context: &Context{
-			DecryptionKeys: testKeys,
-			CognitoClients: []string{testAudience},
+			Region:            testRegion,
+			AllowedUserPoolID: testUserPoolID,
+			DecryptionKeys:    testKeys,
+			CognitoClients:    []string{testAudience},
 		},
 		PolicyBuilder:  policyBuilderMock,
 		ContextBuilder: contextBuilderMock,
@@ -52,8 +69,10 @@ func TestBuildResponsePolicyBuilderError(t *testing.T) {
 
 	responseBuilder := ResponseBuilder{
 		Context: &Context{
-			DecryptionKeys: testKeys,
-			CognitoClients: []string{testAudience},
+			Region:            testRegion,
+			AllowedUserPoolID: testUserPoolID,
+			DecryptionKeys:    testKeys,
+			CognitoClients:    []string{testAudience},
 		},
 		PolicyBuilder:  policyBuilderMock,
 		ContextBuilder: contextBuilderMock,
@@ -79,8 +98,10 @@ func TestBuildResponseContextBuilderError(t *testing.T) {
 
 	responseBuilder := ResponseBuilder{
 		Context: &Context{
-			DecryptionKeys: testKeys,
-			CognitoClients: []string{testAudience},
+			Region:            testRegion,
+			AllowedUserPoolID: testUserPoolID,
+			DecryptionKeys:    testKeys,
+			CognitoClients:    []string{testAudience},
 		},
 		PolicyBuilder:  policyBuilderMock,
 		ContextBuilder: contextBuilderMock,
@@ -102,7 +123,9 @@ func TestBuildResponseBadToken(t *testing.T) {
 
 	responseBuilder := ResponseBuilder{
 		Context: &Context{
-			DecryptionKeys: testKeys,
+			Region:            testRegion,
+			AllowedUserPoolID: testUserPoolID,
+			DecryptionKeys:    testKeys,
 		},
 		PolicyBuilder:  policyBuilderMock,
 		ContextBuilder: contextBuilderMock,
@@ -124,7 +147,9 @@ func TestBuildResponseDescryptionKeysNotFound(t *testing.T) {
 
 	responseBuilder := ResponseBuilder{
 		Context: &Context{
-			DecryptionKeys: []JWKey{},
+			Region:            testRegion,
+			AllowedUserPoolID: testUserPoolID,
+			DecryptionKeys:    []JWKey{},
 		},
 		PolicyBuilder:  policyBuilderMock,
 		ContextBuilder: contextBuilderMock,
@@ -150,8 +175,10 @@ func TestBuildResponseTokenAudienceError(t *testing.T) {
 
 	responseBuilder := ResponseBuilder{
 		Context: &Context{
-			DecryptionKeys: testKeys,
-			CognitoClients: []string{"wrong-audience"},
+			Region:            testRegion,
+			AllowedUserPoolID: testUserPoolID,
+			DecryptionKeys:    testKeys,
+			CognitoClients:    []string{"wrong-audience"},
 		},
 		PolicyBuilder:  policyBuilderMock,
 		ContextBuilder: contextBuilderMock,
@@ -179,8 +206,10 @@ func TestBuildResponseTokenMultipeAudience(t *testing.T) {
 
 	responseBuilder := ResponseBuilder{
 		Context: &Context{
-			DecryptionKeys: testKeys,
-			CognitoClients: []string{"test-audience-2", testAudience},
+			Region:            testRegion,
+			AllowedUserPoolID: testUserPoolID,
+			DecryptionKeys:    testKeys,
+			CognitoClients:    []string{"test-audience-2", testAudience},
 		},
 		PolicyBuilder:  policyBuilderMock,
 		ContextBuilder: contextBuilderMock,
@@ -210,8 +239,10 @@ func TestBuildResponseTokenMultipeAudienceError(t *testing.T) {
 
 	responseBuilder := ResponseBuilder{
 		Context: &Context{
-			DecryptionKeys: testKeys,
-			CognitoClients: []string{"test-audience-1", "test-audience-2"},
+			Region:            testRegion,
+			AllowedUserPoolID: testUserPoolID,
+			DecryptionKeys:    testKeys,
+			CognitoClients:    []string{"test-audience-1", "test-audience-2"},
 		},
 		PolicyBuilder:  policyBuilderMock,
 		ContextBuilder: contextBuilderMock,
@@ -224,3 +255,354 @@ func TestBuildResponseTokenMultipeAudienceError(t *testing.T) {
 	policyBuilderMock.AssertExpectations(t)
 	contextBuilderMock.AssertExpectations(t)
 }
+
+func TestBuildResponseTokenIssuerError(t *testing.T) {
+	testAudience := "test-audience"
+	testKeys := createTestKeys()
+	token := createTestIDToken("test@example.com", "test-subject", testAudience, nil)
+
+	policyBuilderMock := new(policyBuilderMock)
+	contextBuilderMock := new(contextBuilderMock)
+
+	responseBuilder := ResponseBuilder{
+		Context: &Context{
+			Region:            testRegion,
+			AllowedUserPoolID: "eu-west-1_other",
+			DecryptionKeys:    testKeys,
+			CognitoClients:    []string{testAudience},
+		},
+		PolicyBuilder:  policyBuilderMock,
+		ContextBuilder: contextBuilderMock,
+	}
+
+	response, err := responseBuilder.BuildResponse(token)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, events.APIGatewayCustomAuthorizerResponse{}, response)
+	policyBuilderMock.AssertExpectations(t)
+	contextBuilderMock.AssertExpectations(t)
+}
+
+func TestBuildResponseAccessTokenOk(t *testing.T) {
+	testSubject := "test-subject"
+	token := createTestAccessToken("test-scope", testSubject, nil)
+
+	policyBuilderMock := new(policyBuilderMock)
+	policyBuilderMock.On("BuildPolicy", token).Return(events.APIGatewayCustomAuthorizerPolicy{}, nil).Once()
+	contextBuilderMock := new(contextBuilderMock)
+	contextBuilderMock.On("BuildContext", token).Return(map[string]interface{}{}, nil).Once()
+
+	responseBuilder := ResponseBuilder{
+		Context: &Context{
+			Region:            testRegion,
+			AllowedUserPoolID: testUserPoolID,
+			DecryptionKeys:    createTestKeys(),
+			CognitoClients:    []string{"test-audience", testClientID},
+		},
+		PolicyBuilder:  policyBuilderMock,
+		ContextBuilder: contextBuilderMock,
+	}
+
+	response, err := responseBuilder.BuildResponse(token)
+
+	assert.Nil(t, err)
+	assert.Equal(t, testSubject, response.PrincipalID)
+	policyBuilderMock.AssertExpectations(t)
+	contextBuilderMock.AssertExpectations(t)
+}
+
+func TestBuildResponseAccessTokenClientIDError(t *testing.T) {
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+
+	policyBuilderMock := new(policyBuilderMock)
+	contextBuilderMock := new(contextBuilderMock)
+
+	responseBuilder := ResponseBuilder{
+		Context: &Context{
+			Region:            testRegion,
+			AllowedUserPoolID: testUserPoolID,
+			DecryptionKeys:    createTestKeys(),
+			CognitoClients:    []string{"other-client-id"},
+		},
+		PolicyBuilder:  policyBuilderMock,
+		ContextBuilder: contextBuilderMock,
+	}
+
+	response, err := responseBuilder.BuildResponse(token)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, events.APIGatewayCustomAuthorizerResponse{}, response)
+	policyBuilderMock.AssertExpectations(t)
+	contextBuilderMock.AssertExpectations(t)
+}
+
+func TestBuildResponseTokenBuilders(t *testing.T) {
+	testSubject := "test-subject"
+	token := createTestAccessToken("test-scope", testSubject, nil)
+	isVerifiedToken := mock.MatchedBy(func(verified *VerifiedToken) bool {
+		return verified.Raw == token && verified.Access.Scope == "test-scope"
+	})
+	policy := events.APIGatewayCustomAuthorizerPolicy{Version: "2012-10-17"}
+
+	policyBuilderMock := new(tokenPolicyBuilderMock)
+	policyBuilderMock.On("BuildPolicyForToken", isVerifiedToken).Return(policy, nil).Once()
+	contextBuilderMock := new(tokenContextBuilderMock)
+	contextBuilderMock.On("BuildContextForToken", isVerifiedToken).Return(map[string]interface{}{"scope": "test-scope"}, nil).Once()
+
+	responseBuilder := ResponseBuilder{
+		Context: &Context{
+			Region:            testRegion,
+			AllowedUserPoolID: testUserPoolID,
+			DecryptionKeys:    createTestKeys(),
+			CognitoClients:    []string{testClientID},
+		},
+		PolicyBuilder:  policyBuilderMock,
+		ContextBuilder: contextBuilderMock,
+	}
+
+	response, err := responseBuilder.BuildResponse(token)
+
+	assert.Nil(t, err)
+	assert.Equal(t, events.APIGatewayCustomAuthorizerResponse{
+		PrincipalID:    testSubject,
+		PolicyDocument: policy,
+		Context:        map[string]interface{}{"scope": "test-scope"},
+	}, response)
+	policyBuilderMock.AssertExpectations(t)
+	contextBuilderMock.AssertExpectations(t)
+}
+
+func TestBuildResponseErrors(t *testing.T) {
+	testAudience := "test-audience"
+	expiresAt := time.Now().Add(-time.Hour)
+	builderErr := errors.New("builder error")
+	unknownKeyToken := createTestIDToken("", "", testAudience, nil)
+	unknownKeys := createTestKeys()
+	unknownKeys[1].KeyID = "other"
+
+	tests := []struct {
+		name        string
+		token       string
+		keys        []JWKey
+		policyError error
+		want        error
+	}{
+		{name: "malformed", token: "bad-token", want: ErrTokenMalformed},
+		{name: "expired", token: createTestIDToken("", "", testAudience, &expiresAt), want: ErrTokenExpired},
+		{name: "unknownKeyID", token: unknownKeyToken, keys: unknownKeys, want: ErrUnknownKeyID},
+		{name: "audience", token: createTestIDToken("", "", "other-audience", nil), want: ErrAudienceMismatch},
+		{name: "clientID", token: createTestAccessToken("", "", nil), want: ErrAudienceMismatch},
+		{name: "policyBuild", token: createTestIDToken("", "", testAudience, nil), policyError: builderErr, want: ErrPolicyBuild},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := tt.keys
+			if keys == nil {
+				keys = createTestKeys()
+			}
+
+			policyBuilderMock := new(policyBuilderMock)
+			policyBuilderMock.On("BuildPolicy", tt.token).Return(events.APIGatewayCustomAuthorizerPolicy{}, tt.policyError).Maybe()
+
+			responseBuilder := ResponseBuilder{
+				Context: &Context{
+					Region:            testRegion,
+					AllowedUserPoolID: testUserPoolID,
+					DecryptionKeys:    keys,
+					CognitoClients:    []string{testAudience},
+				},
+				PolicyBuilder:  policyBuilderMock,
+				ContextBuilder: new(contextBuilderMock),
+			}
+
+			_, err := responseBuilder.BuildResponse(tt.token)
+
+			assert.Equal(t, "Unauthorized", err.Error())
+			assert.True(t, errors.Is(err, ErrUnauthorized))
+			assert.True(t, errors.Is(err, tt.want), err.(*UnauthorizedError).Err.Error())
+			if tt.policyError != nil {
+				assert.True(t, errors.Is(err, tt.policyError))
+			}
+		})
+	}
+}
+
+func TestBuildResponseTokenUseNotAllowed(t *testing.T) {
+	testAudience := "test-audience"
+	token := createTestIDToken("test@example.com", "test-subject", testAudience, nil)
+
+	policyBuilderMock := new(policyBuilderMock)
+	contextBuilderMock := new(contextBuilderMock)
+
+	responseBuilder := ResponseBuilder{
+		Context: &Context{
+			Region:            testRegion,
+			AllowedUserPoolID: testUserPoolID,
+			DecryptionKeys:    createTestKeys(),
+			CognitoClients:    []string{testAudience},
+		},
+		AllowedTokenUse: AllowAccessTokens,
+		PolicyBuilder:   policyBuilderMock,
+		ContextBuilder:  contextBuilderMock,
+	}
+
+	response, err := responseBuilder.BuildResponse(token)
+
+	assert.True(t, errors.Is(err, ErrTokenUseNotAllowed))
+	assert.Equal(t, events.APIGatewayCustomAuthorizerResponse{}, response)
+	policyBuilderMock.AssertExpectations(t)
+	contextBuilderMock.AssertExpectations(t)
+}
+
+func TestBuildRequestResponseRequestBuilders(t *testing.T) {
+	testSubject := "test-subject"
+	token := createTestAccessToken("test-scope", testSubject, nil)
+	event := events.APIGatewayCustomAuthorizerRequestTypeRequest{
+		Type:           "REQUEST",
+		MethodArn:      "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/items/1",
+		HTTPMethod:     "GET",
+		Headers:        map[string]string{"authorization": "Bearer " + token},
+		PathParameters: map[string]string{"id": "1"},
+		StageVariables: map[string]string{"tenant": "test-tenant"},
+	}
+	isRequest := mock.MatchedBy(func(request *AuthorizerRequest) bool {
+		return request.Token.Raw == token &&
+			request.MethodArn == event.MethodArn &&
+			request.PathParameters["id"] == "1" &&
+			request.StageVariables["tenant"] == "test-tenant"
+	})
+	policy := events.APIGatewayCustomAuthorizerPolicy{Version: "2012-10-17"}
+
+	policyBuilderMock := new(requestPolicyBuilderMock)
+	policyBuilderMock.On("BuildPolicyForRequest", isRequest).Return(policy, nil).Once()
+	contextBuilderMock := new(requestContextBuilderMock)
+	contextBuilderMock.On("BuildContextForRequest", isRequest).Return(map[string]interface{}{"tenant": "test-tenant"}, nil).Once()
+
+	responseBuilder := ResponseBuilder{
+		Context: &Context{
+			Region:            testRegion,
+			AllowedUserPoolID: testUserPoolID,
+			DecryptionKeys:    createTestKeys(),
+			CognitoClients:    []string{testClientID},
+		},
+		PolicyBuilder:  policyBuilderMock,
+		ContextBuilder: contextBuilderMock,
+	}
+
+	response, err := responseBuilder.BuildRequestResponse(event)
+
+	assert.Nil(t, err)
+	assert.Equal(t, events.APIGatewayCustomAuthorizerResponse{
+		PrincipalID:    testSubject,
+		PolicyDocument: policy,
+		Context:        map[string]interface{}{"tenant": "test-tenant"},
+	}, response)
+	policyBuilderMock.AssertExpectations(t)
+	contextBuilderMock.AssertExpectations(t)
+}
+
+func TestBuildRequestResponseTokenBuilders(t *testing.T) {
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+	event := events.APIGatewayCustomAuthorizerRequestTypeRequest{
+		QueryStringParameters: map[string]string{"token": token},
+	}
+
+	policyBuilderMock := new(policyBuilderMock)
+	policyBuilderMock.On("BuildPolicy", token).Return(events.APIGatewayCustomAuthorizerPolicy{}, nil).Once()
+	contextBuilderMock := new(contextBuilderMock)
+	contextBuilderMock.On("BuildContext", token).Return(map[string]interface{}{}, nil).Once()
+
+	responseBuilder := ResponseBuilder{
+		Context: &Context{
+			Region:            testRegion,
+			AllowedUserPoolID: testUserPoolID,
+			DecryptionKeys:    createTestKeys(),
+			CognitoClients:    []string{testClientID},
+		},
+		TokenSource:    TokenSource{QueryString: "token"},
+		PolicyBuilder:  policyBuilderMock,
+		ContextBuilder: contextBuilderMock,
+	}
+
+	_, err := responseBuilder.BuildRequestResponse(event)
+
+	assert.Nil(t, err)
+	policyBuilderMock.AssertExpectations(t)
+	contextBuilderMock.AssertExpectations(t)
+}
+
+func TestBuildRequestResponseTokenMissing(t *testing.T) {
+	responseBuilder := ResponseBuilder{
+		PolicyBuilder:  new(policyBuilderMock),
+		ContextBuilder: new(contextBuilderMock),
+	}
+
+	response, err := responseBuilder.BuildRequestResponse(events.APIGatewayCustomAuthorizerRequestTypeRequest{})
+
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	assert.True(t, errors.Is(err, ErrTokenMissing))
+	assert.Equal(t, events.APIGatewayCustomAuthorizerResponse{}, response)
+}
+
+func TestBuildResponseBearerToken(t *testing.T) {
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+
+	policyBuilderMock := new(policyBuilderMock)
+	policyBuilderMock.On("BuildPolicy", token).Return(events.APIGatewayCustomAuthorizerPolicy{}, nil).Once()
+	contextBuilderMock := new(contextBuilderMock)
+	contextBuilderMock.On("BuildContext", token).Return(map[string]interface{}{}, nil).Once()
+
+	responseBuilder := createTestResponseBuilder(policyBuilderMock, contextBuilderMock)
+
+	_, err := responseBuilder.BuildResponse("Bearer " + token)
+
+	assert.Nil(t, err)
+	policyBuilderMock.AssertExpectations(t)
+	contextBuilderMock.AssertExpectations(t)
+}
+
+func TestBuildResponseUnsupportedScheme(t *testing.T) {
+	responseBuilder := createTestResponseBuilder(new(policyBuilderMock), new(contextBuilderMock))
+
+	_, err := responseBuilder.BuildResponse("Basic dXNlcjpwYXNzd29yZA==")
+
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	assert.True(t, errors.Is(err, ErrUnsupportedScheme))
+}
+
+func TestBuildTokenResponse(t *testing.T) {
+	token := createTestAccessToken("test-scope", "test-subject", nil)
+	methodArn := "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/DELETE/items/1"
+	isRequest := mock.MatchedBy(func(request *AuthorizerRequest) bool {
+		return request.Token.Raw == token &&
+			request.MethodArn == methodArn &&
+			request.HTTPMethod == "DELETE" &&
+			request.Path == "/items/1"
+	})
+
+	policyBuilderMock := new(requestPolicyBuilderMock)
+	policyBuilderMock.On("BuildPolicyForRequest", isRequest).Return(events.APIGatewayCustomAuthorizerPolicy{}, &AccessDeniedError{}).Once()
+	contextBuilderMock := new(contextBuilderMock)
+	contextBuilderMock.On("BuildContext", token).Return(map[string]interface{}{}, nil).Once()
+
+	response, err := createTestResponseBuilder(policyBuilderMock, contextBuilderMock).BuildTokenResponse(events.APIGatewayCustomAuthorizerRequest{
+		Type:               "TOKEN",
+		AuthorizationToken: "Bearer " + token,
+		MethodArn:          methodArn,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, DenyPolicy(methodArn), response.PolicyDocument)
+	policyBuilderMock.AssertExpectations(t)
+	contextBuilderMock.AssertExpectations(t)
+}
+
+func TestBuildTokenResponseTokenMissing(t *testing.T) {
+	response, err := createTestResponseBuilder(new(policyBuilderMock), new(contextBuilderMock)).BuildTokenResponse(events.APIGatewayCustomAuthorizerRequest{
+		MethodArn: "arn:aws:execute-api:eu-west-1:123456789012:api-id/prod/GET/items",
+	})
+
+	assert.True(t, errors.Is(err, ErrTokenMissing))
+	assert.Equal(t, events.APIGatewayCustomAuthorizerResponse{}, response)
+}
@@ -0,0 +1,46 @@
+package authorizer
+
+// TokenUsePolicy tells which token types ResponseBuilder accepts, by token_use claim.
+type TokenUsePolicy int
+
+const (
+	// AllowAnyTokenUse accepts tokens with any token_use, it is the default.
+	AllowAnyTokenUse TokenUsePolicy = iota
+	// AllowAccessTokens accepts only access tokens, as AWS recommends for APIs.
+	AllowAccessTokens
+	// AllowIDTokens accepts only ID tokens.
+	AllowIDTokens
+	// AllowAccessAndIDTokens accepts access and ID tokens, but no other token_use.
+	AllowAccessAndIDTokens
+)
+
+// Allows tells whether token with the given token_use claim is accepted.
+func (p TokenUsePolicy) Allows(tokenUse string) bool {
+	switch p {
+	case AllowAnyTokenUse:
+		return true
+	case AllowAccessTokens:
+		return tokenUse == "access"
+	case AllowIDTokens:
+		return tokenUse == "id"
+	case AllowAccessAndIDTokens:
+		return tokenUse == "access" || tokenUse == "id"
+	}
+
+	return false
+}
+
+func (p TokenUsePolicy) String() string {
+	switch p {
+	case AllowAnyTokenUse:
+		return "any"
+	case AllowAccessTokens:
+		return "access"
+	case AllowIDTokens:
+		return "id"
+	case AllowAccessAndIDTokens:
+		return "access,id"
+	}
+
+	return "none"
+}
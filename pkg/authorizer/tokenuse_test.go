@@ -0,0 +1,29 @@
+package authorizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenUsePolicyAllows(t *testing.T) {
+	tests := []struct {
+		policy TokenUsePolicy
+		access bool
+		id     bool
+		other  bool
+	}{
+		{policy: AllowAnyTokenUse, access: true, id: true, other: true},
+		{policy: AllowAccessTokens, access: true},
+		{policy: AllowIDTokens, id: true},
+		{policy: AllowAccessAndIDTokens, access: true, id: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			assert.Equal(t, tt.access, tt.policy.Allows("access"))
+			assert.Equal(t, tt.id, tt.policy.Allows("id"))
+			assert.Equal(t, tt.other, tt.policy.Allows("refresh"))
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package authorizer
+
+import (
+	"fmt"
+)
+
+// UserPool describes an issuer of accepted tokens: its keys and app clients allowed to call the API.
+// Issuer takes precedence over the issuer built from Region and UserPoolID.
+type UserPool struct {
+	Region         string
+	UserPoolID     string
+	Issuer         string
+	CognitoClients []string
+	Keys           KeyProvider
+}
+
+// NewCognitoUserPool creates a user pool with keys fetched from AWS Cognito.
+func NewCognitoUserPool(region, userPoolID string, clients ...string) *UserPool {
+	return &UserPool{
+		Region:         region,
+		UserPoolID:     userPoolID,
+		CognitoClients: clients,
+		Keys:           NewCognitoKeySet(region, userPoolID),
+	}
+}
+
+// ExpectedIssuer returns issuer of tokens created by the user pool.
+func (p *UserPool) ExpectedIssuer() string {
+	if p.Issuer != "" {
+		return p.Issuer
+	}
+	return fmt.Sprintf(cognitoIssuerTemplate, p.Region, p.UserPoolID)
+}
+
+// pools returns configured user pools, or a single pool described by context fields.
+func (c *Context) pools() []*UserPool {
+	if len(c.UserPools) > 0 {
+		return c.UserPools
+	}
+
+	return []*UserPool{{
+		Region:         c.Region,
+		UserPoolID:     c.AllowedUserPoolID,
+		Issuer:         c.Issuer,
+		CognitoClients: c.CognitoClients,
+		Keys:           c.KeyProvider(),
+	}}
+}
+
+// poolForToken finds user pool that issued the token. The iss claim is read from the token payload,
+// so the issuer is checked whatever claims type the caller parses into.
+func (c *Context) poolForToken(payload *tokenPayload) (*UserPool, error) {
+	issuer := payload.issuer()
+	for _, pool := range c.pools() {
+		if issuer != "" && issuer == pool.ExpectedIssuer() {
+			return pool, nil
+		}
+	}
+
+	return nil, ErrInvalidIssuer
+}
+
+// keyResolver routes token to keys of the user pool that issued it. The matched pool is stored in pool.
+func (c *Context) keyResolver(pool **UserPool) keyResolver {
+	return func(payload *tokenPayload) (KeyProvider, error) {
+		matched, err := c.poolForToken(payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if matched.Keys == nil {
+			return nil, wrapError(ErrKeysUnavailable, fmt.Errorf("user pool %s has no keys", matched.ExpectedIssuer()))
+		}
+
+		*pool = matched
+		return matched.Keys, nil
+	}
+}
@@ -0,0 +1,78 @@
+package authorizer
+
+import (
+	"testing"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+const testCustomerIssuer = "https://cognito-idp.eu-west-1.amazonaws.com/eu-west-1_customers"
+
+func createTestPoolToken(issuer, clientID string) string {
+	claims := AccessTokenClaims{ClientID: clientID}
+	claims.Issuer = issuer
+	claims.TokenUse = "access"
+
+	rsaKey, _ := jwt.ParseRSAPrivateKeyFromPEM([]byte(rawKey))
+	token := jwt.NewWithClaims(jwt.GetSigningMethod("RS256"), claims)
+	token.Header["kid"] = "123456789"
+	tokenString, _ := token.SignedString(rsaKey)
+
+	return tokenString
+}
+
+func createTestMultiPoolVerifier() *Verifier {
+	return NewVerifier(&Context{
+		UserPools: []*UserPool{
+			{
+				Region:         testRegion,
+				UserPoolID:     testUserPoolID,
+				CognitoClients: []string{"staff-client"},
+				Keys:           NewPublicKeys(createTestKeys()),
+			},
+			{
+				Issuer:         testCustomerIssuer,
+				CognitoClients: []string{"customer-client"},
+				Keys:           NewPublicKeys(createTestKeys()),
+			},
+		},
+	})
+}
+
+func TestVerifyMultiplePools(t *testing.T) {
+	verifier := createTestMultiPoolVerifier()
+
+	staffToken, err := verifier.Verify(createTestPoolToken(testIssuer, "staff-client"))
+	assert.Nil(t, err)
+	assert.Equal(t, testUserPoolID, staffToken.UserPool.UserPoolID)
+
+	customerToken, err := verifier.Verify(createTestPoolToken(testCustomerIssuer, "customer-client"))
+	assert.Nil(t, err)
+	assert.Equal(t, testCustomerIssuer, customerToken.UserPool.ExpectedIssuer())
+}
+
+func TestVerifyMultiplePoolsClientOfOtherPool(t *testing.T) {
+	token, err := createTestMultiPoolVerifier().Verify(createTestPoolToken(testCustomerIssuer, "staff-client"))
+
+	assert.NotNil(t, err)
+	assert.Nil(t, token)
+}
+
+func TestVerifyMultiplePoolsUnknownIssuer(t *testing.T) {
+	token, err := createTestMultiPoolVerifier().Verify(createTestPoolToken("https://idp.example.com", "staff-client"))
+
+	assert.Equal(t, ErrInvalidIssuer, err)
+	assert.Nil(t, token)
+}
+
+func TestVerifyMultiplePoolsWithoutKeys(t *testing.T) {
+	verifier := NewVerifier(&Context{
+		UserPools: []*UserPool{{Issuer: testIssuer, CognitoClients: []string{"staff-client"}}},
+	})
+
+	token, err := verifier.Verify(createTestPoolToken(testIssuer, "staff-client"))
+
+	assert.NotNil(t, err)
+	assert.Nil(t, token)
+}
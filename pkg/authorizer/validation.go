@@ -0,0 +1,124 @@
+package authorizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// verifyOptions controls token verification.
+type verifyOptions struct {
+	algorithms  []string
+	leeway      time.Duration
+	maxTokenAge time.Duration
+	maxAuthAge  time.Duration
+	now         func() time.Time
+}
+
+// tokenPayload is decoded from the token payload once. It keeps all claims and the raw payload,
+// and fills claims given by the caller when they are set.
+type tokenPayload struct {
+	raw    []byte
+	values map[string]interface{}
+	claims jwt.Claims
+}
+
+func (p *tokenPayload) UnmarshalJSON(data []byte) error {
+	p.raw = append([]byte(nil), data...)
+	err := json.Unmarshal(data, &p.values)
+	if err != nil || p.claims == nil {
+		return err
+	}
+
+	return json.Unmarshal(data, p.claims)
+}
+
+// Valid does not check anything, the validity period is checked by validateTimes.
+func (p *tokenPayload) Valid() error {
+	return nil
+}
+
+// issuer returns the iss claim, empty when it is missing or not a string.
+func (p *tokenPayload) issuer() string {
+	issuer, _ := p.values["iss"].(string)
+	return issuer
+}
+
+// number returns numeric claim, zero when it is missing.
+func (p *tokenPayload) number(name string) (float64, error) {
+	value, ok := p.values[name]
+	if !ok || value == nil {
+		return 0, nil
+	}
+
+	number, ok := value.(float64)
+	if !ok {
+		return 0, wrapError(ErrTokenMalformed, fmt.Errorf("%s claim is not a number", name))
+	}
+
+	return number, nil
+}
+
+// timeClaims holds token claims describing its validity period.
+type timeClaims struct {
+	ExpiresAt float64
+	NotBefore float64
+	IssuedAt  float64
+	AuthTime  float64
+}
+
+// times reads claims describing the token validity period.
+func (p *tokenPayload) times() (timeClaims, error) {
+	var claims timeClaims
+	var err error
+	for name, value := range map[string]*float64{
+		"exp":       &claims.ExpiresAt,
+		"nbf":       &claims.NotBefore,
+		"iat":       &claims.IssuedAt,
+		"auth_time": &claims.AuthTime,
+	} {
+		*value, err = p.number(name)
+		if err != nil {
+			return timeClaims{}, err
+		}
+	}
+
+	return claims, nil
+}
+
+// validateTimes checks exp, nbf and iat claims allowing clock skew of leeway,
+// and the token and authentication age when the limits are set.
+func validateTimes(payload *tokenPayload, options verifyOptions) error {
+	claims, err := payload.times()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if options.now != nil {
+		now = options.now()
+	}
+	leeway := options.leeway.Seconds()
+	unixNow := float64(now.Unix())
+
+	switch {
+	case claims.ExpiresAt != 0 && unixNow > claims.ExpiresAt+leeway:
+		return ErrTokenExpired
+	case claims.NotBefore != 0 && unixNow < claims.NotBefore-leeway:
+		return ErrTokenNotValidYet
+	case claims.IssuedAt != 0 && unixNow < claims.IssuedAt-leeway:
+		return ErrTokenUsedBeforeIssued
+	}
+
+	if options.maxTokenAge > 0 && (claims.IssuedAt == 0 || unixNow > claims.IssuedAt+options.maxTokenAge.Seconds()+leeway) {
+		return ErrTokenTooOld
+	}
+
+	if options.maxAuthAge > 0 && (claims.AuthTime == 0 || unixNow > claims.AuthTime+options.maxAuthAge.Seconds()+leeway) {
+		return ErrTokenTooOld
+	}
+
+	return nil
+}
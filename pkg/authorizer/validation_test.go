@@ -0,0 +1,109 @@
+package authorizer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestTimedToken(issuedAt, notBefore, expiresAt, authTime time.Time) string {
+	claims := AccessTokenClaims{ClientID: testClientID}
+	claims.Issuer = testIssuer
+	claims.TokenUse = "access"
+	claims.IssuedAt = issuedAt.Unix()
+	claims.NotBefore = notBefore.Unix()
+	claims.ExpiresAt = expiresAt.Unix()
+	claims.AuthTime = authTime.Unix()
+
+	rsaKey, _ := jwt.ParseRSAPrivateKeyFromPEM([]byte(rawKey))
+	token := jwt.NewWithClaims(jwt.GetSigningMethod("RS256"), claims)
+	token.Header["kid"] = "123456789"
+	tokenString, _ := token.SignedString(rsaKey)
+
+	return tokenString
+}
+
+func createTestTimedContext(leeway time.Duration) *Context {
+	return &Context{
+		Region:            testRegion,
+		AllowedUserPoolID: testUserPoolID,
+		CognitoClients:    []string{testClientID},
+		DecryptionKeys:    createTestKeys(),
+		Leeway:            leeway,
+	}
+}
+
+func TestVerifyLeeway(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "issuedInFuture", token: createTestTimedToken(now.Add(30*time.Second), now, now.Add(time.Hour), now)},
+		{name: "notValidYet", token: createTestTimedToken(now, now.Add(30*time.Second), now.Add(time.Hour), now)},
+		{name: "expired", token: createTestTimedToken(now.Add(-time.Hour), now.Add(-time.Hour), now.Add(-30*time.Second), now)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewVerifier(createTestTimedContext(0)).Verify(tt.token)
+			assert.NotNil(t, err)
+
+			token, err := NewVerifier(createTestTimedContext(time.Minute)).Verify(tt.token)
+			assert.Nil(t, err)
+			assert.NotNil(t, token)
+		})
+	}
+}
+
+func TestVerifyLeewayExceeded(t *testing.T) {
+	now := time.Now()
+	token := createTestTimedToken(now.Add(2*time.Minute), now, now.Add(time.Hour), now)
+
+	_, err := NewVerifier(createTestTimedContext(time.Minute)).Verify(token)
+
+	assert.Equal(t, ErrTokenUsedBeforeIssued, err)
+}
+
+func TestVerifyMaxTokenAge(t *testing.T) {
+	now := time.Now()
+	context := createTestTimedContext(0)
+	context.MaxTokenAge = 10 * time.Minute
+
+	_, err := NewVerifier(context).Verify(createTestTimedToken(now.Add(-5*time.Minute), now, now.Add(time.Hour), now))
+	assert.Nil(t, err)
+
+	_, err = NewVerifier(context).Verify(createTestTimedToken(now.Add(-15*time.Minute), now, now.Add(time.Hour), now))
+	assert.Equal(t, ErrTokenTooOld, err)
+}
+
+func TestVerifyMaxAuthAge(t *testing.T) {
+	now := time.Now()
+	context := createTestTimedContext(0)
+	context.MaxAuthAge = 12 * time.Hour
+
+	_, err := NewVerifier(context).Verify(createTestTimedToken(now, now, now.Add(time.Hour), now.Add(-time.Hour)))
+	assert.Nil(t, err)
+
+	_, err = NewVerifier(context).Verify(createTestTimedToken(now, now, now.Add(time.Hour), now.Add(-24*time.Hour)))
+	assert.Equal(t, ErrTokenTooOld, err)
+}
+
+func TestVerifyTimeClaimNotNumber(t *testing.T) {
+	rsaKey, _ := jwt.ParseRSAPrivateKeyFromPEM([]byte(rawKey))
+	token := jwt.NewWithClaims(jwt.GetSigningMethod("RS256"), jwt.MapClaims{
+		"iss":       testIssuer,
+		"client_id": testClientID,
+		"token_use": "access",
+		"exp":       "never",
+	})
+	token.Header["kid"] = "123456789"
+	tokenString, _ := token.SignedString(rsaKey)
+
+	_, err := NewVerifier(createTestTimedContext(0)).Verify(tokenString)
+
+	assert.True(t, errors.Is(err, ErrTokenMalformed))
+}
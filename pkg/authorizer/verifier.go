@@ -0,0 +1,120 @@
+package authorizer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// VerifiedToken holds data of a token that passed verification.
+// ID is set for `id` tokens and Access for `access` tokens. UserPool is the pool that issued the token.
+type VerifiedToken struct {
+	Raw      string
+	UserPool *UserPool
+	Header   map[string]interface{}
+	Base     BaseTokenClaims
+	ID       *IDTokenClaims
+	Access   *AccessTokenClaims
+	Claims   map[string]interface{}
+}
+
+// Verifier verifies tokens against the context: signature, expiration, issuer and client.
+// Token is parsed once, the result can be shared by policy and context builders.
+type Verifier struct {
+	Context *Context
+}
+
+// NewVerifier creates a verifier for the given context.
+func NewVerifier(context *Context) *Verifier {
+	return &Verifier{Context: context}
+}
+
+// Verify verifies the token and decodes all of its claims.
+func (v *Verifier) Verify(encodedToken string) (*VerifiedToken, error) {
+	var pool *UserPool
+	parsed, payload, err := parseWithClaims(encodedToken, nil, v.Context.keyResolver(&pool), v.Context.verifyOptions())
+	if err == ErrInvalidIssuer {
+		log.WithField("issuer", payload.issuer()).Error("Failed to verify token issuer.")
+		return nil, err
+	}
+
+	if err != nil {
+		log.WithField("error", err).Info("Failed to verify token.")
+		return nil, err
+	}
+
+	token, err := decodeVerifiedToken(parsed, payload)
+	if err != nil {
+		return nil, err
+	}
+	token.UserPool = pool
+
+	if token.Access != nil {
+		err = verifyClientID(pool, token.Access)
+	} else {
+		err = verifyAudience(pool, &token.Base)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// decodeVerifiedToken decodes typed claims of the token, the claims map is the one decoded while parsing.
+func decodeVerifiedToken(parsed *jwt.Token, payload *tokenPayload) (*VerifiedToken, error) {
+	token := &VerifiedToken{
+		Raw:    parsed.Raw,
+		Header: parsed.Header,
+		Claims: payload.values,
+	}
+
+	var err error
+	switch tokenUse, _ := payload.values["token_use"].(string); tokenUse {
+	case "access":
+		token.Access = &AccessTokenClaims{}
+		err = json.Unmarshal(payload.raw, token.Access)
+		token.Base = token.Access.BaseTokenClaims
+	case "id":
+		token.ID = &IDTokenClaims{}
+		err = json.Unmarshal(payload.raw, token.ID)
+		token.ID.Claims = token.Claims
+		token.Base = token.ID.BaseTokenClaims
+	default:
+		err = json.Unmarshal(payload.raw, &token.Base)
+	}
+
+	if err != nil {
+		return nil, wrapError(ErrTokenMalformed, err)
+	}
+
+	return token, nil
+}
+
+// verifyAudience checks that token audience is one of clients allowed in the pool.
+func verifyAudience(pool *UserPool, claims *BaseTokenClaims) error {
+	for _, client := range pool.CognitoClients {
+		if claims.VerifyAudience(client, true) {
+			return nil
+		}
+	}
+
+	log.WithField("audience", claims.Audience).Error("Failed to verify token audience.")
+	return ErrAudienceMismatch
+}
+
+// verifyClientID checks that access token was issued to one of clients allowed in the pool.
+// Access tokens have no audience field, the client is stored in client_id claim.
+func verifyClientID(pool *UserPool, claims *AccessTokenClaims) error {
+	for _, client := range pool.CognitoClients {
+		if claims.VerifyClientID(client) {
+			return nil
+		}
+	}
+
+	log.WithField("client_id", claims.ClientID).Error("Failed to verify token client id.")
+	return wrapError(ErrAudienceMismatch, fmt.Errorf("client id %s is not allowed", claims.ClientID))
+}
@@ -0,0 +1,121 @@
+package authorizer
+
+import (
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestVerifier(clients ...string) *Verifier {
+	return NewVerifier(&Context{
+		Region:            testRegion,
+		AllowedUserPoolID: testUserPoolID,
+		CognitoClients:    clients,
+		DecryptionKeys:    createTestKeys(),
+	})
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	testEmail := "test@example.com"
+	testAudience := "test-audience"
+	encodedToken := createTestIDToken(testEmail, "test-subject", testAudience, nil)
+
+	token, err := createTestVerifier(testAudience).Verify(encodedToken)
+
+	assert.Nil(t, err)
+	assert.Equal(t, encodedToken, token.Raw)
+	assert.Equal(t, "123456789", token.Header["kid"])
+	assert.Equal(t, "test-subject", token.Base.Subject)
+	assert.Equal(t, testEmail, token.ID.Email)
+	assert.Equal(t, testEmail, token.Claims["email"])
+	assert.Equal(t, testEmail, token.ID.Claims["email"])
+	assert.Nil(t, token.Access)
+}
+
+func TestVerifyAccessToken(t *testing.T) {
+	encodedToken := createTestAccessToken("test-scope", "test-subject", nil)
+
+	token, err := createTestVerifier(testClientID).Verify(encodedToken)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "test-scope", token.Access.Scope)
+	assert.Equal(t, testClientID, token.Access.ClientID)
+	assert.Equal(t, "access", token.Claims["token_use"])
+	assert.Nil(t, token.ID)
+}
+
+func TestVerifyExpiredToken(t *testing.T) {
+	expiresAt := time.Now().Add(-time.Hour)
+	encodedToken := createTestAccessToken("test-scope", "test-subject", &expiresAt)
+
+	token, err := createTestVerifier(testClientID).Verify(encodedToken)
+
+	assert.NotNil(t, err)
+	assert.Nil(t, token)
+}
+
+func TestVerifyInvalidIssuer(t *testing.T) {
+	encodedToken := createTestAccessToken("test-scope", "test-subject", nil)
+	verifier := createTestVerifier(testClientID)
+	verifier.Context.Region = "us-east-1"
+
+	token, err := verifier.Verify(encodedToken)
+
+	assert.Equal(t, ErrInvalidIssuer, err)
+	assert.Nil(t, token)
+}
+
+func TestVerifyClientIDNotAllowed(t *testing.T) {
+	encodedToken := createTestAccessToken("test-scope", "test-subject", nil)
+
+	token, err := createTestVerifier("test-audience").Verify(encodedToken)
+
+	assert.NotNil(t, err)
+	assert.Nil(t, token)
+}
+
+func TestVerifyGroupClaims(t *testing.T) {
+	claims := IDTokenClaims{
+		Groups:        []string{"admins", "users"},
+		Roles:         []string{"arn:aws:iam::123456789012:role/admin"},
+		PreferredRole: "arn:aws:iam::123456789012:role/admin",
+	}
+	claims.Audience = Audience{"test-audience"}
+	claims.Issuer = testIssuer
+	claims.TokenUse = "id"
+
+	rsaKey, _ := jwt.ParseRSAPrivateKeyFromPEM([]byte(rawKey))
+	encodedToken := jwt.NewWithClaims(jwt.GetSigningMethod("RS256"), claims)
+	encodedToken.Header["kid"] = "123456789"
+	tokenString, _ := encodedToken.SignedString(rsaKey)
+
+	token, err := createTestVerifier("test-audience").Verify(tokenString)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"admins", "users"}, token.ID.Groups)
+	assert.Equal(t, claims.Roles, token.ID.Roles)
+	assert.Equal(t, claims.PreferredRole, token.ID.PreferredRole)
+	assert.Equal(t, []interface{}{"admins", "users"}, token.Claims["cognito:groups"])
+}
+
+func TestVerifyAudienceList(t *testing.T) {
+	rsaKey, _ := jwt.ParseRSAPrivateKeyFromPEM([]byte(rawKey))
+	encodedToken := jwt.NewWithClaims(jwt.GetSigningMethod("RS256"), jwt.MapClaims{
+		"iss":       testIssuer,
+		"aud":       []string{"other-audience", "test-audience"},
+		"token_use": "id",
+	})
+	encodedToken.Header["kid"] = "123456789"
+	tokenString, _ := encodedToken.SignedString(rsaKey)
+
+	token, err := createTestVerifier("test-audience").Verify(tokenString)
+
+	assert.Nil(t, err)
+	assert.Equal(t, Audience{"other-audience", "test-audience"}, token.Base.Audience)
+
+	_, err = createTestVerifier("third-audience").Verify(tokenString)
+
+	assert.Equal(t, ErrAudienceMismatch, err)
+}